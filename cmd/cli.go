@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	args    string // Positional arguments after the flags, e.g. "[config-file]"
+	summary string
+	// settings lists the environment variables of the settings the command takes as flags,
+	// every setting when nil
+	settings []string
+	options  []option // Flags of the command itself
+	run      func(ctx context.Context, inv *invocation) int
+}
+
+// option is a flag that belongs to a command rather than to the config
+type option struct {
+	name  string
+	value string // Default value
+	usage string
+}
+
+// invocation is a parsed command line
+type invocation struct {
+	args    []string          // Positional arguments after the flags
+	flags   map[string]string // Flags that were set, keyed by the environment variable they stand for
+	options map[string]string // Command options, keyed by name, with defaults for those not set
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// defaultCommand runs when no command is given
+const defaultCommand = "run"
+
+var commands = []*command{
+	{name: "run", summary: "Check instances once and exit (default)", run: runOnce},
+	{name: "cron", summary: "Check instances on the schedule until interrupted", run: runCron},
+	{name: "digest", summary: "Send a digest of every outstanding instance without acting on any", run: runDigest},
+	{
+		name:    "list",
+		summary: "List the instances each target matches and when they are due, without acting",
+		options: []option{{name: "output", value: "table", usage: "output `format`: table, json or csv"}},
+		run:     runList,
+	},
+	{name: "explain", args: "<instance-id>", summary: "Explain how each target evaluates one instance, without acting", run: runExplain},
+	{name: "validate", args: "[config-file]", summary: "Validate a config file without calling AWS", settings: []string{"CONFIG_PATH"}, run: runValidate},
+}
+
+// settingUsage describes every setting for the --help output, keyed by environment variable
+var settingUsage = map[string]string{
+	"AWS_REGION":              "AWS `region` of the SDK clients, scanned when no regions are set (required)",
+	"AWS_REGIONS":             "comma-separated `regions` to scan, defaults to the region",
+	"SNS_TOPIC_ARN":           "SNS topic `arn` for notifications",
+	"SCHEDULE":                "cron `schedule` of the checks (required by cron)",
+	"DRY_RUN":                 "log and notify without acting on instances (default true)",
+	"LEADER_ELECTION_ENABLED": "only run checks while holding the Kubernetes lease",
+	"POD_NAME":                "`name` of this pod for leader election",
+	"POD_NAMESPACE":           "`namespace` of the leader election lease",
+	"LEASE_NAME":              "`name` of the leader election lease (default ec2-checker-leader)",
+	"VPC_ID":                  "only check instances in this `vpc`",
+	"ACCOUNTS":                "JSON list of `accounts` to assume roles in, scanned besides the default credentials' account",
+	"INCLUDE_DEFAULT_ACCOUNT": "also scan the default credentials' account when accounts are set (default true)",
+	"HTTP_ADDR":               "`address` of the metrics and health server in cron mode",
+	"HEALTH_MISSED_RUNS":      "scheduled `runs` that may be missed before liveness fails (default 3)",
+	"CONFIG_RELOAD_INTERVAL":  "`interval` at which cron mode checks the config file for changes, 0 disables (default 30s)",
+	"CONFIG_PATH":             "`path` of the config file (required)",
+	"TARGET_SELECTION":        "`mode` choosing the target for instances matching several: first or mostSpecific (default first)",
+	"NOTIFIERS":               "JSON list of `notifiers`: sns, slack, teams, webhook or smtp destinations",
+	"OWNER_TAG":               "instance `tag` naming the owner sent personal notifications (default Owner)",
+	"NOTIFY_CHANGES_ONLY":     "only notify about new instances and changed outcomes",
+	"NOTIFICATION_STATE_PATH": "`path` of the file remembering notified instances across restarts",
+	"DIGEST_SCHEDULE":         "cron `schedule` of digests of every outstanding instance in cron mode",
+}
+
+// runCLI runs the command named by the first argument and returns the process exit code
+func runCLI(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	initLogger(stderr)
+
+	name := defaultCommand
+	switch {
+	case len(args) == 1 && isHelpFlag(args[0]):
+		printUsage(stdout)
+		return 0
+	case len(args) > 0 && !strings.HasPrefix(args[0], "-"):
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		if len(args) == 0 {
+			printUsage(stdout)
+			return 0
+		}
+		// help <command> is the same as <command> --help
+		name, args = args[0], []string{"--help"}
+	}
+
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		printUsage(stderr)
+		return 2
+	}
+
+	inv, err := cmd.parse(args, stdout, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+	return cmd.run(ctx, inv)
+}
+
+// lookupCommand returns the command with the given name, or nil if there is none
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ec2-checker <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Settings are read from the config file, then environment variables, then flags.")
+	fmt.Fprintln(w, `Run "ec2-checker <command> --help" for the flags of a command.`)
+}
+
+// parse parses the flags and positional arguments of the command. Help is printed to
+// stdout and flag errors to stderr.
+func (c *command) parse(args []string, stdout, stderr io.Writer) (*invocation, error) {
+	inv := &invocation{flags: map[string]string{}, options: map[string]string{}, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	for _, setting := range config.Settings() {
+		if c.settings != nil && !slices.Contains(c.settings, setting.Env) {
+			continue
+		}
+		usage := fmt.Sprintf("%s\n$%s", settingUsage[setting.Env], setting.Env)
+		fs.Var(&settingFlag{setting: setting, values: inv.flags}, setting.Flag, usage)
+	}
+	for _, opt := range c.options {
+		inv.options[opt.name] = opt.value
+		fs.Func(opt.name, fmt.Sprintf("%s (default %s)", opt.usage, opt.value), func(value string) error {
+			inv.options[opt.name] = value
+			return nil
+		})
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\n%s\n\nFlags:\n", strings.TrimSpace("ec2-checker "+c.name+" [flags] "+c.args), c.summary)
+		fs.PrintDefaults()
+	}
+
+	// -h and --help are not errors, so their output goes to stdout
+	if slices.ContainsFunc(args, isHelpFlag) {
+		fs.SetOutput(stdout)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	inv.args = fs.Args()
+	if c.args == "" && len(inv.args) > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %s\n", strings.Join(inv.args, " "))
+		fs.Usage()
+		return nil, fmt.Errorf("unexpected arguments")
+	}
+	return inv, nil
+}
+
+// isHelpFlag reports whether the argument asks for help
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// settingFlag is a flag for a config setting. Its value is recorded under the setting's
+// environment variable, so config.LoadWithFlags parses it like the environment.
+type settingFlag struct {
+	setting config.Setting
+	values  map[string]string
+}
+
+func (f *settingFlag) String() string {
+	return f.values[f.setting.Env]
+}
+
+func (f *settingFlag) Set(value string) error {
+	f.values[f.setting.Env] = value
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be given as --dry-run instead of --dry-run=true
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.setting.Bool
+}
+
+// runOnce checks the instances once and fails if any scan or action failed
+func runOnce(ctx context.Context, inv *invocation) int {
+	return runSingle(ctx, inv, (*checker.Checker).RunCheck)
+}
+
+// runDigest notifies every outstanding instance once without acting on any, and fails if
+// any scan failed
+func runDigest(ctx context.Context, inv *invocation) int {
+	return runSingle(ctx, inv, (*checker.Checker).RunDigest)
+}
+
+// runSingle runs a single check with run and fails if any scan or action failed
+func runSingle(ctx context.Context, inv *invocation, run func(*checker.Checker, context.Context) *checker.RunReport) int {
+	_, chk, err := loadChecker(ctx, inv.flags)
+	if err != nil {
+		slog.Error("Failed to start", "error", err)
+		return 1
+	}
+
+	slog.Info("Starting single run...")
+	if report := run(chk, ctx); report.Failed() {
+		slog.Error("Check completed with errors", "errors", report.Errors())
+		return 1
+	}
+	return 0
+}
+
+// runCron checks the instances on the schedule until ctx is done
+func runCron(ctx context.Context, inv *invocation) int {
+	cfg, chk, err := loadChecker(ctx, inv.flags)
+	if err != nil {
+		slog.Error("Failed to start", "error", err)
+		return 1
+	}
+
+	if err := runCronMode(ctx, cfg, chk); err != nil {
+		slog.Error("Cron mode failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// newChecker builds the checker for the loaded config, replaced by tests to avoid AWS
+var newChecker = initChecker
+
+// loadChecker resolves the configuration with the flags and builds the checker
+func loadChecker(ctx context.Context, flags map[string]string) (*config.Config, *checker.Checker, error) {
+	cfg, err := config.LoadWithFlags(flags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	slog.Info("Loaded config", "path", cfg.ConfigPath, "config", cfg.Redacted())
+	logTargetWarnings(cfg)
+
+	chk, err := newChecker(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize checker: %w", err)
+	}
+	return cfg, chk, nil
+}
+
+// runValidate validates the config file given as argument, by --config-path or by
+// CONFIG_PATH, printing one line per problem
+func runValidate(ctx context.Context, inv *invocation) int {
+	path := os.Getenv("CONFIG_PATH")
+	if flagPath, ok := inv.flags["CONFIG_PATH"]; ok {
+		path = flagPath
+	}
+	if len(inv.args) > 0 {
+		path = inv.args[0]
+	}
+	if path == "" || len(inv.args) > 1 {
+		fmt.Fprintln(inv.stderr, "usage: ec2-checker validate [config-file]  (defaults to --config-path or $CONFIG_PATH)")
+		return 2
+	}
+
+	file, err := config.LoadFile(path)
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			for _, fieldErr := range validationErr.Errors {
+				fmt.Fprintf(inv.stderr, "%s: %s\n", path, fieldErr)
+			}
+		} else {
+			fmt.Fprintf(inv.stderr, "%s: %v\n", path, err)
+		}
+		return 1
+	}
+
+	for _, warning := range file.TargetWarnings() {
+		fmt.Fprintf(inv.stdout, "%s: warning: %s\n", path, warning)
+	}
+	fmt.Fprintf(inv.stdout, "%s: valid, %d targets\n", path, len(file.Targets))
+	return 0
+}
+
+// logTargetWarnings logs the overlapping targets of the config
+func logTargetWarnings(cfg *config.Config) {
+	for _, warning := range cfg.TargetWarnings() {
+		slog.Warn("Targets overlap", "field", warning.Field, "warning", warning.Message)
+	}
+}
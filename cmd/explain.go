@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// runExplain prints why each target does or does not match the instance and what the
+// checker would do with it
+func runExplain(ctx context.Context, inv *invocation) int {
+	if len(inv.args) != 1 {
+		fmt.Fprintln(inv.stderr, "usage: ec2-checker explain [flags] <instance-id>")
+		return 2
+	}
+
+	cfg, chk, err := loadChecker(ctx, inv.flags)
+	if err != nil {
+		slog.Error("Failed to start", "error", err)
+		return 1
+	}
+
+	explanation, err := chk.Explain(ctx, inv.args[0])
+	if err != nil {
+		fmt.Fprintln(inv.stderr, err)
+		return 1
+	}
+	writeExplanation(inv.stdout, explanation, cfg.DryRun)
+	return 0
+}
+
+// writeExplanation prints the explanation as text
+func writeExplanation(w io.Writer, e *checker.Explanation, dryRun bool) {
+	instance := e.Instance
+	fmt.Fprintf(w, "Instance %s", aws.ToString(instance.InstanceId))
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == "Name" {
+			fmt.Fprintf(w, " (%s)", aws.ToString(tag.Value))
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  Type:     %s\n", instance.InstanceType)
+	fmt.Fprintf(w, "  Location: %s\n", scopeName(checker.ScopeReport{AccountID: e.Scope.AccountID, Region: e.Scope.Region}))
+	if instance.VpcId != nil {
+		fmt.Fprintf(w, "  VPC:      %s\n", aws.ToString(instance.VpcId))
+	}
+	fmt.Fprintf(w, "  Launched: %s (%.1f hours ago)\n", aws.ToTime(instance.LaunchTime).UTC().Format(time.RFC3339), e.Runtime.Hours())
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Targets:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, match := range e.Targets {
+		result := "no match"
+		switch {
+		case e.Finding != nil && e.Finding.TargetIndex == match.TargetIndex:
+			result = "MATCH"
+		case match.Matched:
+			result = "match"
+		}
+		fmt.Fprintf(tw, "  targets[%d]\t%s\t%s\n", match.TargetIndex, result, match.Reason)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Result:")
+	for _, line := range explainResult(e, dryRun) {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+// explainResult summarizes what the checker would do with the instance
+func explainResult(e *checker.Explanation, dryRun bool) []string {
+	if e.NotChecked != "" {
+		return []string{"Not checked: " + e.NotChecked}
+	}
+	finding := e.Finding
+	if finding == nil {
+		return []string{"No target matches, the instance is never acted on"}
+	}
+
+	target := finding.Target
+	action := target.EffectiveAction()
+	limit := fmt.Sprintf("of %g hours", target.MaxRuntimeHours)
+	if !target.HasRuntimeLimit() {
+		limit = "hours, no limit"
+	}
+	lines := []string{
+		fmt.Sprintf("Target:  targets[%d] (%s)", finding.TargetIndex, finding.Selection),
+		fmt.Sprintf("Runtime: %.1f %s (%s basis, since %s)", finding.Runtime.Hours(), limit, finding.RuntimeBasis, finding.RuntimeStart.UTC().Format(time.RFC3339)),
+	}
+	if finding.Override != "" {
+		lines = append(lines, "Tags:    "+finding.Override)
+	}
+	switch {
+	case finding.Window != "":
+		lines = append(lines, "Window:  outside "+finding.Window)
+	case len(target.RunWindows) > 0:
+		lines = append(lines, "Window:  inside "+target.RunWindows.String())
+	}
+
+	due := e.DueAt.UTC().Format(time.RFC3339)
+	switch finding.Decision {
+	case checker.DecisionSkip:
+		lines = append(lines, fmt.Sprintf("Action:  none, exempt from %s", action))
+	case checker.DecisionWarn:
+		lines = append(lines, fmt.Sprintf("Action:  warning due now, %s at %s", action, due))
+	case checker.DecisionPending:
+		lines = append(lines, fmt.Sprintf("Action:  warned, %s in %.1f hours, at %s", action, time.Until(e.DueAt).Hours(), due))
+	case checker.DecisionAct:
+		line := fmt.Sprintf("Action:  %s due now", action)
+		if dryRun {
+			line += " (dry run, only logged)"
+		}
+		lines = append(lines, line)
+	default:
+		if e.DueAt.IsZero() {
+			lines = append(lines, fmt.Sprintf("Action:  %s never due, the run windows stay open and there is no runtime limit", action))
+		} else {
+			lines = append(lines, fmt.Sprintf("Action:  %s in %.1f hours, at %s", action, time.Until(e.DueAt).Hours(), due))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
+)
+
+// listFormats are the output formats of the list command
+var listFormats = map[string]func(w io.Writer, entries []checker.ListEntry) error{
+	"table": writeListTable,
+	"json":  writeListJSON,
+	"csv":   writeListCSV,
+}
+
+// runList prints every instance matched by a target. It only describes instances, so it
+// is safe to run with the production config.
+func runList(ctx context.Context, inv *invocation) int {
+	write, ok := listFormats[inv.options["output"]]
+	if !ok {
+		fmt.Fprintf(inv.stderr, "unknown output format %q, expected table, json or csv\n", inv.options["output"])
+		return 2
+	}
+
+	_, chk, err := loadChecker(ctx, inv.flags)
+	if err != nil {
+		slog.Error("Failed to start", "error", err)
+		return 1
+	}
+
+	entries, scopes := chk.List(ctx)
+	if err := write(inv.stdout, entries); err != nil {
+		slog.Error("Failed to write list", "error", err)
+		return 1
+	}
+
+	// Instances in failed scopes are missing from the list, so the list is incomplete
+	failed := false
+	for _, scope := range scopes {
+		for _, scanErr := range scope.Errors {
+			fmt.Fprintf(inv.stderr, "%s: %s\n", scopeName(scope), scanErr)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// scopeName returns the account and region of a scope for messages
+func scopeName(scope checker.ScopeReport) string {
+	if scope.AccountID == "" {
+		return scope.Region
+	}
+	return scope.AccountID + "/" + scope.Region
+}
+
+// listColumns are the header of the table and CSV output
+var listColumns = []string{"ACCOUNT", "REGION", "INSTANCE", "NAME", "TYPE", "TARGET", "RUNTIME_HOURS", "BASIS", "THRESHOLD_HOURS", "HOURS_REMAINING", "ACTION", "DECISION"}
+
+// listRow formats an entry as the columns of listColumns
+func listRow(entry checker.ListEntry) []string {
+	remaining := "never"
+	switch {
+	case entry.Decision == checker.DecisionSkip:
+		remaining = "exempt"
+	case entry.HoursRemaining != nil:
+		remaining = strconv.FormatFloat(*entry.HoursRemaining, 'f', 1, 64)
+	}
+	return []string{
+		entry.AccountID,
+		entry.Region,
+		entry.InstanceID,
+		entry.Name,
+		entry.InstanceType,
+		strconv.Itoa(entry.TargetIndex),
+		strconv.FormatFloat(entry.RuntimeHours, 'f', 1, 64),
+		string(entry.RuntimeBasis),
+		strconv.FormatFloat(entry.ThresholdHours, 'f', 1, 64),
+		remaining,
+		string(entry.Action),
+		string(entry.Decision),
+	}
+}
+
+func writeListTable(w io.Writer, entries []checker.ListEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeLine := func(columns []string) {
+		for i, column := range columns {
+			if column == "" {
+				column = "-"
+			}
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, column)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	writeLine(listColumns)
+	for _, entry := range entries {
+		writeLine(listRow(entry))
+	}
+	return tw.Flush()
+}
+
+func writeListJSON(w io.Writer, entries []checker.ListEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+func writeListCSV(w io.Writer, entries []checker.ListEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write(listColumns)
+	for _, entry := range entries {
+		cw.Write(listRow(entry))
+	}
+	cw.Flush()
+	return cw.Error()
+}
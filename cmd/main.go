@@ -2,56 +2,45 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
 	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/health"
 	"github.com/rayselfs/aws-ec2-runtime-checker/internal/k8s"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/metrics"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/go-co-op/gocron/v2"
 
 	"k8s.io/client-go/tools/leaderelection"
 )
 
 func main() {
-	initLogger()
-
-	cfg, err := config.Load()
-	if err != nil {
-		slog.Error("Failed to load config", "error", err)
-		os.Exit(1)
-	}
-
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
-	chk, err := initChecker(ctx, cfg)
-	if err != nil {
-		slog.Error("Failed to initialize checker", "error", err)
-		os.Exit(1)
-	}
-
-	if isCronMode() {
-		if err := runCronMode(ctx, cfg, chk); err != nil {
-			slog.Error("Cron mode failed", "error", err)
-			os.Exit(1)
-		}
-	} else {
-		slog.Info("Starting single run...")
-		chk.RunCheck(ctx)
-	}
+	code := runCLI(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
 }
 
-func initLogger() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+// initLogger sends the logs to w, which is stderr so that they never mix with the output
+// of commands such as list and explain
+func initLogger(w io.Writer) {
+	logger := slog.New(slog.NewJSONHandler(w, nil))
 	slog.SetDefault(logger)
 }
 
@@ -64,29 +53,144 @@ func initChecker(ctx context.Context, cfg *config.Config) (*checker.Checker, err
 	ec2Client := ec2.NewFromConfig(awsCfg)
 	snsClient := sns.NewFromConfig(awsCfg)
 
-	return checker.New(ec2Client, snsClient, cfg), nil
+	chk := checker.New(ec2Client, snsClient, cfg)
+	for _, account := range accountConfigs(awsCfg, cfg.Accounts, cfg.IncludeDefaultAccount) {
+		for _, region := range cfg.Regions() {
+			chk.Scopes = append(chk.Scopes, checker.Scope{
+				AccountID: account.id,
+				Region:    region,
+				EC2Client: ec2.NewFromConfig(account.cfg, func(o *ec2.Options) {
+					o.Region = region
+				}),
+			})
+		}
+	}
+	slog.Info("Scanning scopes", "accounts", len(cfg.Accounts), "regions", cfg.Regions())
+
+	return chk, nil
 }
 
-func isCronMode() bool {
-	args := os.Args[1:]
-	return len(args) > 0 && args[0] == "cron"
+type accountConfig struct {
+	id  string
+	cfg aws.Config
+}
+
+// accountConfigs returns one SDK config per configured account, each with credentials
+// from assuming the account's role, after the default credentials when includeDefault is
+// set. Without accounts, the default credentials are always used. Roles are assumed lazily,
+// so an account that cannot be assumed only fails its own scans.
+func accountConfigs(awsCfg aws.Config, accounts config.Accounts, includeDefault bool) []accountConfig {
+	if len(accounts) == 0 {
+		return []accountConfig{{cfg: awsCfg}}
+	}
+
+	stsClient := sts.NewFromConfig(awsCfg)
+	result := make([]accountConfig, 0, len(accounts)+1)
+	if includeDefault {
+		result = append(result, accountConfig{cfg: awsCfg})
+	}
+	for _, account := range accounts {
+		provider := stscreds.NewAssumeRoleProvider(stsClient, account.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = account.EffectiveSessionName()
+			if account.ExternalID != "" {
+				o.ExternalID = aws.String(account.ExternalID)
+			}
+		})
+
+		accountCfg := awsCfg.Copy()
+		accountCfg.Credentials = aws.NewCredentialsCache(provider)
+		result = append(result, accountConfig{id: account.AccountID(), cfg: accountCfg})
+	}
+	return result
 }
 
 func runCronMode(ctx context.Context, cfg *config.Config, chk *checker.Checker) error {
 	slog.Info("Starting in cron mode...")
 
 	if cfg.Schedule == "" {
-		return fmt.Errorf("SCHEDULE environment variable is required in cron mode")
+		return fmt.Errorf("schedule is required in cron mode, set it in the config file, by SCHEDULE or by --schedule")
 	}
 	slog.Info("Using cron schedule", "schedule", cfg.Schedule)
 
+	h, err := health.New(cfg.Schedule, cfg.HealthMissedRuns)
+	if err != nil {
+		return fmt.Errorf("failed to set up health checks: %w", err)
+	}
+	mon := &monitor{metrics: metrics.New(), health: h}
+
+	if cfg.HTTPAddr != "" {
+		startHTTPServer(ctx, cfg.HTTPAddr, mon)
+	}
+	if cfg.ConfigReloadInterval > 0 {
+		go watchTargets(ctx, cfg, chk)
+	}
+
 	if cfg.LeaderElectionEnabled {
-		return runWithLeaderElection(ctx, cfg, chk)
+		return runWithLeaderElection(ctx, cfg, chk, mon)
 	}
-	return runSimpleScheduler(ctx, cfg, chk)
+	mon.setLeader(true)
+	return runSimpleScheduler(ctx, cfg, chk, mon)
+}
+
+// watchTargets swaps the checker's targets between runs whenever the config file changes
+func watchTargets(ctx context.Context, cfg *config.Config, chk *checker.Checker) {
+	config.WatchTargets(ctx, cfg.ConfigPath, time.Duration(cfg.ConfigReloadInterval), cfg.AllNotifiers(), func(targets []config.Target) {
+		// Scopes are built at startup, so new regions need a restart
+		reloaded := *cfg
+		reloaded.Targets = targets
+		for _, region := range reloaded.Regions() {
+			if !slices.Contains(cfg.Regions(), region) {
+				slog.Warn("Region added by config reload is only scanned after a restart", "region", region)
+			}
+		}
+		logTargetWarnings(&reloaded)
+		chk.SetTargets(targets)
+		slog.Info("Targets reloaded", "targets", len(targets))
+	})
+}
+
+// monitor feeds scheduler and leader state to the metrics and health endpoints
+type monitor struct {
+	metrics *metrics.Metrics
+	health  *health.Health
+}
+
+func (mon *monitor) setLeader(leading bool) {
+	mon.metrics.SetLeader(leading)
+	mon.health.SetLeader(leading)
+}
+
+// runCheck runs a single check and records its completion
+func (mon *monitor) runCheck(ctx context.Context, chk *checker.Checker) {
+	mon.metrics.RecordRun(chk.RunCheck(ctx))
+	mon.health.RunCompleted()
 }
 
-func runWithLeaderElection(ctx context.Context, cfg *config.Config, chk *checker.Checker) error {
+// startHTTPServer serves the metrics and probe endpoints in the background until ctx is done
+func startHTTPServer(ctx context.Context, addr string, mon *monitor) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", mon.metrics)
+	mux.Handle("GET /healthz", mon.health.LivenessHandler())
+	mux.Handle("GET /readyz", mon.health.ReadinessHandler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		slog.Info("Starting HTTP server", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shutdown HTTP server", "error", err)
+		}
+	}()
+}
+
+func runWithLeaderElection(ctx context.Context, cfg *config.Config, chk *checker.Checker, mon *monitor) error {
 	if cfg.PodName == "" || cfg.PodNamespace == "" {
 		return fmt.Errorf("POD_NAME and POD_NAMESPACE are required for leader election")
 	}
@@ -105,15 +209,18 @@ func runWithLeaderElection(ctx context.Context, cfg *config.Config, chk *checker
 		RetryPeriod:   2 * time.Second,
 	}
 
+	mon.setLeader(false)
 	callbacks := leaderelection.LeaderCallbacks{
 		OnStartedLeading: func(ctx context.Context) {
 			slog.Info("Became leader, starting check loop...")
-			if err := runSimpleScheduler(ctx, cfg, chk); err != nil {
+			mon.setLeader(true)
+			if err := runSimpleScheduler(ctx, cfg, chk, mon); err != nil {
 				slog.Error("Scheduler failed", "error", err)
 			}
 		},
 		OnStoppedLeading: func() {
 			slog.Info("Lost leadership, exiting...")
+			mon.setLeader(false)
 			os.Exit(0)
 		},
 		OnNewLeader: func(identity string) {
@@ -129,7 +236,7 @@ func runWithLeaderElection(ctx context.Context, cfg *config.Config, chk *checker
 	return nil
 }
 
-func runSimpleScheduler(ctx context.Context, cfg *config.Config, chk *checker.Checker) error {
+func runSimpleScheduler(ctx context.Context, cfg *config.Config, chk *checker.Checker, mon *monitor) error {
 	s, err := gocron.NewScheduler()
 	if err != nil {
 		return fmt.Errorf("failed to create scheduler: %w", err)
@@ -138,18 +245,32 @@ func runSimpleScheduler(ctx context.Context, cfg *config.Config, chk *checker.Ch
 	_, err = s.NewJob(
 		gocron.CronJob(cfg.Schedule, false),
 		gocron.NewTask(func() {
-			chk.RunCheck(ctx)
+			mon.runCheck(ctx, chk)
 		}),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create job: %w", err)
 	}
 
+	if cfg.DigestSchedule != "" {
+		_, err = s.NewJob(
+			gocron.CronJob(cfg.DigestSchedule, false),
+			gocron.NewTask(func() {
+				// A digest neither acts nor counts as a check, so it is not recorded
+				chk.RunDigest(ctx)
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create digest job: %w", err)
+		}
+		slog.Info("Using digest schedule", "schedule", cfg.DigestSchedule)
+	}
+
 	s.Start()
 	slog.Info("Scheduler started")
 
 	// Run once immediately
-	chk.RunCheck(ctx)
+	mon.runCheck(ctx, chk)
 
 	// Block until context is done
 	<-ctx.Done()
@@ -1,44 +1,408 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
-func TestIsCronMode(t *testing.T) {
+// MockEC2Client serves DescribeInstances from a fixed list and fails every action, since
+// the read-only commands must never call them
+type MockEC2Client struct {
+	Instances []types.Instance
+}
+
+func (m *MockEC2Client) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: m.Instances}}}, nil
+}
+
+func (m *MockEC2Client) TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+	panic("unexpected TerminateInstances")
+}
+
+func (m *MockEC2Client) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	panic("unexpected StopInstances")
+}
+
+func (m *MockEC2Client) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	panic("unexpected CreateTags")
+}
+
+// useMockChecker makes the commands build their checker on the instances instead of AWS,
+// with the targets config file at CONFIG_PATH
+func useMockChecker(t *testing.T, targets string, instances ...types.Instance) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(targets), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	logger := slog.Default()
+	t.Cleanup(func() {
+		newChecker = initChecker
+		slog.SetDefault(logger)
+	})
+	newChecker = func(ctx context.Context, cfg *config.Config) (*checker.Checker, error) {
+		return checker.New(&MockEC2Client{Instances: instances}, nil, cfg), nil
+	}
+}
+
+func TestRunCLI_Help(t *testing.T) {
 	tests := []struct {
-		name string
-		args []string
-		want bool
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+		wantStderr []string
 	}{
+		{name: "help command", args: []string{"help"}, wantStdout: []string{"Commands:", "run", "cron", "validate"}},
+		{name: "help flag", args: []string{"--help"}, wantStdout: []string{"Commands:"}},
+		{name: "command help", args: []string{"run", "--help"}, wantStdout: []string{"-aws-region region", "$AWS_REGION", "-dry-run", "-config-reload-interval"}},
+		{name: "help for a command", args: []string{"help", "cron"}, wantStdout: []string{"ec2-checker cron", "-schedule"}},
+		{name: "unknown command", args: []string{"explode"}, wantCode: 2, wantStderr: []string{`unknown command "explode"`, "Commands:"}},
+		{name: "unknown flag", args: []string{"run", "--explode"}, wantCode: 2, wantStderr: []string{"flag provided but not defined: -explode"}},
+		{name: "unexpected argument", args: []string{"cron", "now"}, wantCode: 2, wantStderr: []string{"unexpected arguments: now"}},
+		{name: "unknown list format", args: []string{"list", "--output", "yaml"}, wantCode: 2, wantStderr: []string{`unknown output format "yaml"`}},
+		{name: "list help", args: []string{"list", "--help"}, wantStdout: []string{"-output format", "(default table)", "-aws-region"}},
+		{name: "explain without instance", args: []string{"explain"}, wantCode: 2, wantStderr: []string{"<instance-id>"}},
+		{name: "validate has no AWS flags", args: []string{"validate", "--aws-region", "us-east-1"}, wantCode: 2, wantStderr: []string{"flag provided but not defined: -aws-region"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			code := runCLI(context.Background(), tt.args, &stdout, &stderr)
+
+			if code != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d", tt.wantCode, code)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Expected stdout to contain %q, got %q", want, stdout.String())
+				}
+			}
+			for _, want := range tt.wantStderr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("Expected stderr to contain %q, got %q", want, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCommandParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		args      []string
+		wantFlags map[string]string
+		wantArgs  []string
+	}{
+		{name: "no flags", command: "run", wantFlags: map[string]string{}},
 		{
-			name: "cron mode enabled",
-			args: []string{"cron"},
-			want: true,
+			name:      "settings are keyed by environment variable",
+			command:   "run",
+			args:      []string{"--aws-region", "us-west-2", "--aws-regions=us-east-1,eu-west-1", "--dry-run=false"},
+			wantFlags: map[string]string{"AWS_REGION": "us-west-2", "AWS_REGIONS": "us-east-1,eu-west-1", "DRY_RUN": "false"},
 		},
 		{
-			name: "cron mode disabled - no args",
-			args: []string{},
-			want: false,
+			name:      "boolean flag without value",
+			command:   "cron",
+			args:      []string{"-leader-election-enabled", "--schedule", "*/5 * * * *"},
+			wantFlags: map[string]string{"LEADER_ELECTION_ENABLED": "true", "SCHEDULE": "*/5 * * * *"},
 		},
 		{
-			name: "cron mode disabled - different arg",
-			args: []string{"run"},
-			want: false,
+			name:      "positional argument",
+			command:   "validate",
+			args:      []string{"--config-path", "a.json", "b.json"},
+			wantFlags: map[string]string{"CONFIG_PATH": "a.json"},
+			wantArgs:  []string{"b.json"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Save original args
-			oldArgs := os.Args
-			defer func() { os.Args = oldArgs }()
+			var stdout, stderr strings.Builder
+			inv, err := lookupCommand(tt.command).parse(tt.args, &stdout, &stderr)
+			if err != nil {
+				t.Fatalf("parse() failed: %v, %s", err, stderr.String())
+			}
+			if !maps.Equal(inv.flags, tt.wantFlags) {
+				t.Errorf("Expected flags %v, got %v", tt.wantFlags, inv.flags)
+			}
+			if !slices.Equal(inv.args, tt.wantArgs) {
+				t.Errorf("Expected args %v, got %v", tt.wantArgs, inv.args)
+			}
+		})
+	}
+}
+
+func TestSettingUsage(t *testing.T) {
+	for _, setting := range config.Settings() {
+		if settingUsage[setting.Env] == "" {
+			t.Errorf("Expected usage text for %s", setting.Env)
+		}
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	validPath := filepath.Join(dir, "valid.json")
+	invalidPath := filepath.Join(dir, "invalid.json")
+	os.WriteFile(validPath, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`), 0o644)
+	overlapPath := filepath.Join(dir, "overlap.json")
+	os.WriteFile(overlapPath, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24}, {"tags": {"Team": "ci"}, "maxRuntimeHours": 8}]`), 0o644)
+	os.WriteFile(invalidPath, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 0, "action": "explode"}]`), 0o644)
+
+	tests := []struct {
+		name       string
+		args       []string
+		configPath string
+		wantCode   int
+		wantOutput []string
+	}{
+		{name: "valid file argument", args: []string{validPath}, wantCode: 0, wantOutput: []string{"valid, 1 targets"}},
+		{name: "overlapping targets", args: []string{overlapPath}, wantCode: 0, wantOutput: []string{"warning: targets[1]: may select the same instances as targets[0]", "valid, 2 targets"}},
+		{name: "CONFIG_PATH fallback", configPath: validPath, wantCode: 0, wantOutput: []string{"valid, 1 targets"}},
+		{name: "invalid file", args: []string{invalidPath}, wantCode: 1, wantOutput: []string{"targets[0].maxRuntimeHours: must be greater than 0", `targets[0].action: invalid action "explode"`}},
+		{name: "missing file", args: []string{filepath.Join(dir, "missing.json")}, wantCode: 1, wantOutput: []string{"failed to open config file"}},
+		{name: "config path flag", args: []string{"--config-path", validPath}, wantCode: 0, wantOutput: []string{"valid, 1 targets"}},
+		{name: "argument over flag", args: []string{"--config-path", invalidPath, validPath}, wantCode: 0, wantOutput: []string{"valid, 1 targets"}},
+		{name: "no path", wantCode: 2, wantOutput: []string{"usage"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_PATH", tt.configPath)
+
+			var stdout, stderr strings.Builder
+			code := runCLI(context.Background(), append([]string{"validate"}, tt.args...), &stdout, &stderr)
 
-			// Set test args
-			os.Args = append([]string{"cmd"}, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d", tt.wantCode, code)
+			}
+			output := stdout.String() + stderr.String()
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected output to contain %q, got %q", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCLI_ListJSON(t *testing.T) {
+	useMockChecker(t, `[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`, types.Instance{
+		InstanceId:   aws.String("i-1"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-30 * time.Hour)),
+	})
 
-			if got := isCronMode(); got != tt.want {
-				t.Errorf("isCronMode() = %v, want %v", got, tt.want)
+	var stdout, stderr strings.Builder
+	if code := runCLI(context.Background(), []string{"list", "--output", "json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d, stderr:\n%s", code, stderr.String())
+	}
+
+	// The logs go to stderr, so stdout is the list alone
+	var entries []checker.ListEntry
+	if err := json.Unmarshal([]byte(stdout.String()), &entries); err != nil {
+		t.Fatalf("Expected stdout to be JSON, got %v:\n%s", err, stdout.String())
+	}
+	if len(entries) != 1 || entries[0].InstanceID != "i-1" || entries[0].Decision != checker.DecisionAct {
+		t.Errorf("Expected the due instance, got %+v", entries)
+	}
+	if !strings.Contains(stderr.String(), `"msg":"Loaded config"`) {
+		t.Errorf("Expected the logs on stderr, got %q", stderr.String())
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	remaining := 2.5
+	entries := []checker.ListEntry{
+		{Region: "us-east-1", InstanceID: "i-1", InstanceType: "t2.micro", Name: "build, nightly", TargetIndex: 0, RuntimeHours: 21.5, RuntimeBasis: config.BasisLastStart, ThresholdHours: 24, HoursRemaining: &remaining, Action: config.ActionStop, Decision: checker.DecisionNone},
+		{AccountID: "111111111111", Region: "eu-west-1", InstanceID: "i-2", InstanceType: "m5.large", TargetIndex: 1, RuntimeHours: 30, RuntimeBasis: config.BasisCumulative, ThresholdHours: 8, Action: config.ActionTerminate, Decision: checker.DecisionSkip},
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: "table", want: []string{"INSTANCE", "i-1", "build, nightly", "21.5", "2.5", "stop", "111111111111", "exempt"}},
+		{format: "json", want: []string{`"instanceId": "i-1"`, `"runtimeBasis": "cumulative"`, `"hoursRemaining": 2.5`, `"hoursRemaining": null`, `"accountId": "111111111111"`}},
+		{format: "csv", want: []string{"ACCOUNT,REGION,INSTANCE", `,us-east-1,i-1,"build, nightly",t2.micro,0,21.5,lastStart,24.0,2.5,stop,none`, "111111111111,eu-west-1,i-2,,m5.large,1,30.0,cumulative,8.0,exempt,terminate,skip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			if err := listFormats[tt.format](&out, entries); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunCLI_Explain(t *testing.T) {
+	useMockChecker(t, `[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`, types.Instance{
+		InstanceId:   aws.String("i-1"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-30 * time.Hour)),
+	})
+
+	var stdout, stderr strings.Builder
+	if code := runCLI(context.Background(), []string{"explain", "i-1"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d, stderr:\n%s", code, stderr.String())
+	}
+
+	// The logs go to stderr, so stdout is the explanation alone
+	if !strings.HasPrefix(stdout.String(), "Instance i-1") {
+		t.Errorf("Expected stdout to start with the explanation, got:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), `"msg"`) {
+		t.Errorf("Expected no logs on stdout, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), `"msg":"Loaded config"`) {
+		t.Errorf("Expected the logs on stderr, got %q", stderr.String())
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	instance := types.Instance{
+		InstanceId:   aws.String("i-build"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-30 * time.Hour)),
+		Tags:         []types.Tag{{Key: aws.String("Name"), Value: aws.String("build-01")}},
+	}
+	target := config.Target{Name: "build-*", MaxRuntimeHours: 24, Action: config.ActionStop}
+
+	tests := []struct {
+		name        string
+		explanation *checker.Explanation
+		dryRun      bool
+		want        []string
+	}{
+		{
+			name: "due",
+			explanation: &checker.Explanation{
+				Instance: instance,
+				Scope:    checker.Scope{AccountID: "111111111111", Region: "eu-west-1"},
+				Runtime:  30 * time.Hour,
+				Targets: []checker.TargetMatch{
+					{TargetIndex: 0, Reason: `instance type "t2.micro" does not match "m5.large"`},
+					{TargetIndex: 1, Matched: true},
+					{TargetIndex: 2, Matched: true, Reason: "targets[1] takes precedence (earlier in the config)"},
+				},
+				Finding: &checker.Finding{
+					Target: target, TargetIndex: 1, Selection: "earlier in the config", Decision: checker.DecisionAct,
+					Runtime: 30 * time.Hour, RuntimeBasis: config.BasisFirstLaunch, RuntimeStart: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			dryRun: true,
+			want: []string{
+				"Instance i-build (build-01)",
+				"111111111111/eu-west-1",
+				`targets[0]  no match  instance type "t2.micro" does not match "m5.large"`,
+				"targets[1]  MATCH",
+				"Target:  targets[1] (earlier in the config)",
+				"targets[2]  match     targets[1] takes precedence (earlier in the config)",
+				"Runtime: 30.0 of 24 hours (firstLaunch basis, since 2026-01-01T00:00:00Z)",
+				"stop due now (dry run, only logged)",
+			},
+		},
+		{
+			name: "within limit",
+			explanation: &checker.Explanation{
+				Instance: instance,
+				Runtime:  20 * time.Hour,
+				Targets:  []checker.TargetMatch{{TargetIndex: 0, Matched: true}},
+				Finding:  &checker.Finding{Target: target, Runtime: 20 * time.Hour, Decision: checker.DecisionNone},
+				DueAt:    time.Now().Add(4 * time.Hour),
+			},
+			want: []string{"stop in 4.0 hours"},
+		},
+		{
+			name: "outside run windows",
+			explanation: &checker.Explanation{
+				Instance: instance,
+				Runtime:  2 * time.Hour,
+				Targets:  []checker.TargetMatch{{TargetIndex: 0, Matched: true}},
+				Finding: &checker.Finding{
+					Target:   config.Target{Name: "build-*", Action: config.ActionStop, RunWindows: config.RunWindows{{Days: []string{"Mon-Fri"}, Start: "08:00", End: "20:00"}}},
+					Runtime:  2 * time.Hour,
+					Decision: checker.DecisionAct,
+					Window:   "Mon-Fri 08:00-20:00 UTC",
+				},
+			},
+			want: []string{"Runtime: 2.0 hours, no limit", "Window:  outside Mon-Fri 08:00-20:00 UTC", "stop due now"},
+		},
+		{
+			name: "no match",
+			explanation: &checker.Explanation{
+				Instance: instance,
+				Targets:  []checker.TargetMatch{{TargetIndex: 0, Reason: "tag \"Team\" is missing"}},
+			},
+			want: []string{"No target matches"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			writeExplanation(&out, tt.explanation, tt.dryRun)
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAccountConfigs(t *testing.T) {
+	accounts := config.Accounts{{RoleArn: "arn:aws:iam::111111111111:role/checker"}, {RoleArn: "arn:aws:iam::222222222222:role/checker"}}
+
+	tests := []struct {
+		name           string
+		accounts       config.Accounts
+		includeDefault bool
+		want           []string
+	}{
+		{name: "default credentials only", want: []string{""}},
+		{name: "default credentials without accounts", includeDefault: false, want: []string{""}},
+		{name: "accounts and default credentials", accounts: accounts, includeDefault: true, want: []string{"", "111111111111", "222222222222"}},
+		{name: "accounts only", accounts: accounts, want: []string{"111111111111", "222222222222"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, account := range accountConfigs(aws.Config{}, tt.accounts, tt.includeDefault) {
+				ids = append(ids, account.id)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Expected accounts %q, got %q", tt.want, ids)
 			}
 		})
 	}
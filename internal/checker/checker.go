@@ -4,11 +4,16 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/notifier"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -19,41 +24,289 @@ import (
 type EC2API interface {
 	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
 	TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
 }
 
 type SNSAPI interface {
 	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
 }
 
+const (
+	// TagExceededAt is written by the tag action with the time the threshold was exceeded
+	TagExceededAt = "runtime-checker/exceeded-at"
+
+	// TagWarnedAt is written when an instance is first warned about; the action is
+	// deferred until the target's grace period has elapsed since this time
+	TagWarnedAt = "runtime-checker/warned-at"
+)
+
+// Scope is an account/region pair scanned by the checker together with the EC2 client for it
+type Scope struct {
+	AccountID string // Empty when using the default credentials
+	Region    string
+	EC2Client EC2API
+}
+
+// Decision is what the checker decided to do with an instance that matched a target
+type Decision string
+
+const (
+	// DecisionNone leaves an instance that is within its runtime limit alone
+	DecisionNone Decision = "none"
+	// DecisionSkip leaves an instance alone because its tags exempt it
+	DecisionSkip Decision = "skip"
+	// DecisionWarn warns about the instance and defers the action
+	DecisionWarn Decision = "warn"
+	// DecisionPending leaves a warned instance alone until its action is due
+	DecisionPending Decision = "pending"
+	// DecisionAct takes the target's action on the instance
+	DecisionAct Decision = "act"
+)
+
+// Finding is the checker's evaluation of an instance that matched a target
+type Finding struct {
+	Instance    types.Instance
+	Target      config.Target // Adjusted by the instance's override tags
+	TargetIndex int           // Position of the target in Config.Targets
+	Selection   string        // Why the target won over other matching targets
+	Runtime     time.Duration
+	Scope       Scope
+	Decision    Decision
+
+	// DueAt is when the runtime action will be taken, zero when it is due now or the target
+	// has no runtime limit
+	DueAt time.Time
+	// Override explains how the instance's tags changed its evaluation, if they did
+	Override string
+	// Window describes the run windows the instance was found outside of, if it was
+	Window string
+
+	// RuntimeBasis is the basis Runtime was measured on and RuntimeStart the time it was
+	// measured from
+	RuntimeBasis config.RuntimeBasis
+	RuntimeStart time.Time
+	// UptimeTags are the cumulative basis bookkeeping tags to write on the instance
+	UptimeTags map[string]string
+}
+
 type Checker struct {
 	EC2Client EC2API
-	SNSClient SNSAPI
 	Config    *config.Config
+
+	// SNSClient publishes to SNS_TOPIC_ARN when the checker has no Notifiers, such as a
+	// checker built without New
+	SNSClient SNSAPI
+
+	// Notifiers receive the notification of every run with warned or acted on instances
+	Notifiers []notifier.Notifier
+
+	// Scopes lists the accounts and regions to scan. When empty, EC2Client is used for AWS_REGION only.
+	Scopes []Scope
+
+	// runMu serializes runs with target reloads, so a run never sees a partial swap
+	runMu sync.Mutex
+
+	// notified remembers what each notifier was told, nil to notify every run in full
+	notified *notificationState
 }
 
+// New returns a checker with a notifier for each notifier in the config, SNS ones publishing
+// with snsClient
 func New(ec2Client EC2API, snsClient SNSAPI, cfg *config.Config) *Checker {
-	return &Checker{
+	c := &Checker{
 		EC2Client: ec2Client,
 		SNSClient: snsClient,
 		Config:    cfg,
 	}
+	for _, notifierConfig := range cfg.AllNotifiers() {
+		n, err := notifier.New(notifierConfig, snsClient)
+		if err != nil {
+			slog.Error("Skipping notifier", "notifier", notifierConfig.Name, "error", err)
+			continue
+		}
+		c.Notifiers = append(c.Notifiers, n)
+	}
+	if cfg.NotifyChangesOnly {
+		c.notified = loadNotificationState(cfg.NotificationStatePath)
+	}
+	return c
 }
 
-func (c *Checker) RunCheck(ctx context.Context) {
+// RunCheck scans all scopes, warns about or acts on due instances, sends the notification
+// and returns a report of everything it did
+func (c *Checker) RunCheck(ctx context.Context) *RunReport {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
 	slog.Info("Checking for long-running instances...")
+	report := &RunReport{StartedAt: time.Now(), DryRun: c.Config.DryRun}
 
-	longRunningInstances := c.findLongRunningInstances(ctx)
-	if len(longRunningInstances) == 0 {
+	findings, scopes := c.findLongRunningInstances(ctx)
+	report.Scopes = scopes
+	report.Instances = c.processInstances(ctx, findings)
+	report.FinishedAt = time.Now()
+
+	if report.Count(DecisionWarn)+report.Count(DecisionAct) == 0 {
 		slog.Info("No long-running instances found")
-		return
+	} else {
+		c.sendNotifications(ctx, report)
+	}
+	if c.notified != nil {
+		c.notified.forget(report)
+		c.notified.save()
+	}
+
+	slog.Info("Check completed",
+		"scanned", report.Scanned(),
+		"matched", len(report.Instances),
+		"warned", report.Count(DecisionWarn),
+		"acted", report.Count(DecisionAct),
+		"errors", len(report.Errors()),
+		"duration", report.Duration().String())
+	return report
+}
+
+// RunDigest scans all scopes like List and notifies every instance due for a warning or the
+// action, or warned and waiting for it, whether or not it was notified before. It never
+// warns about, tags or acts on instances and leaves the notification state alone, so the
+// next check still notifies only what changed since the last one.
+func (c *Checker) RunDigest(ctx context.Context) *RunReport {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	slog.Info("Building digest of outstanding instances...")
+	report := &RunReport{StartedAt: time.Now(), DryRun: c.Config.DryRun, Digest: true}
+
+	findings, scopes := c.findLongRunningInstances(ctx)
+	report.Scopes = scopes
+	for _, finding := range findings {
+		report.Instances = append(report.Instances, newInstanceReport(finding))
+	}
+	report.FinishedAt = time.Now()
+
+	if report.Outstanding() == 0 {
+		slog.Info("No outstanding instances, skipping digest")
+	} else {
+		c.sendNotifications(ctx, report)
+	}
+
+	slog.Info("Digest completed",
+		"scanned", report.Scanned(),
+		"matched", len(report.Instances),
+		"warned", report.Count(DecisionWarn),
+		"pending", report.Count(DecisionPending),
+		"due", report.Count(DecisionAct),
+		"errors", len(report.Errors()),
+		"duration", report.Duration().String())
+	return report
+}
+
+// buildQueries plans the DescribeInstances calls needed to cover every target.
+// Targets sharing the same Name and tag filters are grouped into one query, since
+// unioning their instance types still yields a superset of each target. A target
+// without any server-side filter needs all running instances, so it replaces the
+// other queries entirely.
+func (c *Checker) buildQueries(targets []config.Target) [][]types.Filter {
+	groups := make(map[string][]config.Target)
+	var keys []string
+	for _, t := range targets {
+		key := queryKey(t)
+		if key == "" && t.InstanceType == "" {
+			return [][]types.Filter{c.buildFilters(nil)}
+		}
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
 	}
 
-	message := c.processInstances(ctx, longRunningInstances)
-	c.sendNotification(ctx, message)
+	queries := make([][]types.Filter, 0, len(keys))
+	for _, key := range keys {
+		queries = append(queries, c.buildFilters(groups[key]))
+	}
+	return queries
+}
+
+// queryKey identifies targets whose Name, tag and match expression filters are identical
+func queryKey(t config.Target) string {
+	var parts []string
+	for _, filter := range targetFilters(t) {
+		parts = append(parts, aws.ToString(filter.Name)+"="+strings.Join(filter.Values, "\x01"))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\x00")
+}
+
+// targetFilters returns the server-side filters of a target other than its instance type:
+// its Name pattern, its tags and the match expressions EC2 can evaluate. NotIn, DoesNotExist
+// and Regex have no EC2 equivalent and are left to matchesTarget, like Glob patterns EC2
+// cannot express.
+func targetFilters(t config.Target) []types.Filter {
+	var filters []types.Filter
+	if name, ok := serverSideName(t.Name); ok {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:Name"),
+			Values: []string{name},
+		})
+	}
+
+	tagKeys := make([]string, 0, len(t.Tags))
+	for key := range t.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		filters = append(filters, types.Filter{
+			Name:   aws.String(fmt.Sprintf("tag:%s", key)),
+			Values: []string{t.Tags[key]},
+		})
+	}
+
+	for _, r := range t.MatchExpressions {
+		switch r.Operator {
+		case config.OpIn:
+			filters = append(filters, types.Filter{
+				Name:   aws.String(fmt.Sprintf("tag:%s", r.Key)),
+				Values: r.Values,
+			})
+		case config.OpExists:
+			filters = append(filters, types.Filter{
+				Name:   aws.String("tag-key"),
+				Values: []string{r.Key},
+			})
+		case config.OpGlob:
+			patterns := make([]string, 0, len(r.Values))
+			for _, value := range r.Values {
+				if pattern, ok := serverSideName(value); ok {
+					patterns = append(patterns, pattern)
+				}
+			}
+			// A pattern EC2 cannot express could match instances the others do not
+			if len(patterns) == len(r.Values) {
+				filters = append(filters, types.Filter{
+					Name:   aws.String(fmt.Sprintf("tag:%s", r.Key)),
+					Values: patterns,
+				})
+			}
+		}
+	}
+	return filters
 }
 
-// buildFilters constructs EC2 API filters based on configuration
-func (c *Checker) buildFilters() []types.Filter {
+// serverSideName returns the Name or tag pattern if EC2 can evaluate it as a filter value.
+// EC2 only understands the * and ? wildcards, so character classes and escapes are
+// left to matchesTarget.
+func serverSideName(pattern string) (string, bool) {
+	if pattern == "" || strings.ContainsAny(pattern, "[\\") {
+		return "", false
+	}
+	return pattern, true
+}
+
+// buildFilters constructs EC2 API filters for a group of targets sharing one query.
+// The targets are expected to have identical Name, tag and match expression filters (see queryKey).
+func (c *Checker) buildFilters(targets []config.Target) []types.Filter {
 	filters := []types.Filter{
 		{
 			Name:   aws.String("instance-state-name"),
@@ -61,10 +314,14 @@ func (c *Checker) buildFilters() []types.Filter {
 		},
 	}
 
-	// Collect instance types for filtering
+	// Collect instance types for filtering, unless a target accepts any type
 	var instanceTypes []string
-	for _, t := range c.Config.Targets {
-		if t.InstanceType != "" {
+	for _, t := range targets {
+		if t.InstanceType == "" {
+			instanceTypes = nil
+			break
+		}
+		if !slices.Contains(instanceTypes, t.InstanceType) {
 			instanceTypes = append(instanceTypes, t.InstanceType)
 		}
 	}
@@ -83,138 +340,372 @@ func (c *Checker) buildFilters() []types.Filter {
 		})
 	}
 
-	// Collect tag filters
-	tagFilters := make(map[string][]string)
-	for _, t := range c.Config.Targets {
-		for key, value := range t.Tags {
-			tagKey := fmt.Sprintf("tag:%s", key)
-			if _, exists := tagFilters[tagKey]; !exists {
-				tagFilters[tagKey] = []string{}
-			}
-			tagFilters[tagKey] = append(tagFilters[tagKey], value)
+	if len(targets) == 0 {
+		return filters
+	}
+
+	// Name, tag and match expression filters are shared by the whole group
+	return append(filters, targetFilters(targets[0])...)
+}
+
+// SetTargets replaces the configured targets, waiting for a running check to finish first
+func (c *Checker) SetTargets(targets []config.Target) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	cfg := *c.Config
+	cfg.Targets = targets
+	c.Config = &cfg
+}
+
+// findLongRunningInstances scans all scopes concurrently and returns their findings in scope order.
+// A scope that fails (e.g. an account whose role cannot be assumed) does not affect the others.
+func (c *Checker) findLongRunningInstances(ctx context.Context) ([]Finding, []ScopeReport) {
+	scopes := c.scopes()
+	findings := make([][]Finding, len(scopes))
+	reports := make([]ScopeReport, len(scopes))
+
+	var wg sync.WaitGroup
+	for i, scope := range scopes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			findings[i], reports[i] = c.scanScope(ctx, scope)
+		}()
+	}
+	wg.Wait()
+
+	return slices.Concat(findings...), reports
+}
+
+// scopes returns the configured scopes, or a single scope for AWS_REGION using EC2Client
+func (c *Checker) scopes() []Scope {
+	if len(c.Scopes) > 0 {
+		return c.Scopes
+	}
+	return []Scope{{Region: c.Config.AWSRegion, EC2Client: c.EC2Client}}
+}
+
+// scanScope queries EC2 in one scope and evaluates every instance matching a target.
+// Instances returned by more than one query are only evaluated once.
+func (c *Checker) scanScope(ctx context.Context, scope Scope) ([]Finding, ScopeReport) {
+	start := time.Now()
+	report := ScopeReport{AccountID: scope.AccountID, Region: scope.Region}
+
+	var targets []config.Target
+	var targetIndexes []int
+	for i, t := range c.Config.Targets {
+		if c.Config.TargetInRegion(t, scope.Region) {
+			targets = append(targets, t)
+			targetIndexes = append(targetIndexes, i)
 		}
 	}
-	for tagKey, values := range tagFilters {
-		filters = append(filters, types.Filter{
-			Name:   aws.String(tagKey),
-			Values: values,
-		})
+	if len(targets) == 0 {
+		return nil, report
 	}
 
-	return filters
+	var findings []Finding
+	seen := make(map[string]bool)
+
+	for _, filters := range c.buildQueries(targets) {
+		report.Queries++
+		queryStart := time.Now()
+		instances, err := describeInstances(ctx, scope.EC2Client, filters)
+		report.DescribeDurations = append(report.DescribeDurations, time.Since(queryStart))
+		if err != nil {
+			slog.Error("Failed to describe instances", "account", scope.AccountID, "region", scope.Region, "error", err)
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+
+		for _, instance := range instances {
+			instanceID := aws.ToString(instance.InstanceId)
+			if seen[instanceID] {
+				continue
+			}
+			seen[instanceID] = true
+			report.Scanned++
+
+			finding := c.checkInstanceRuntime(instance, targets)
+			if finding == nil {
+				continue
+			}
+			finding.TargetIndex = targetIndexes[finding.TargetIndex]
+			finding.Scope = scope
+			findings = append(findings, *finding)
+		}
+	}
+
+	report.Matched = len(findings)
+	report.Duration = time.Since(start)
+	return findings, report
 }
 
-// findLongRunningInstances queries EC2 and filters instances that exceed runtime thresholds
-func (c *Checker) findLongRunningInstances(ctx context.Context) []types.Instance {
-	filters := c.buildFilters()
+// describeInstances returns every instance matching the filters across all pages
+func describeInstances(ctx context.Context, client EC2API, filters []types.Filter) ([]types.Instance, error) {
 	input := &ec2.DescribeInstancesInput{
 		Filters: filters,
 	}
 
-	paginator := ec2.NewDescribeInstancesPaginator(c.EC2Client, input)
-	var longRunningInstances []types.Instance
+	paginator := ec2.NewDescribeInstancesPaginator(client, input)
+	var instances []types.Instance
 
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			slog.Error("Failed to describe instances", "error", err)
-			return nil
+			return nil, err
 		}
 
 		for _, reservation := range page.Reservations {
-			for _, instance := range reservation.Instances {
-				if instance := c.checkInstanceRuntime(instance); instance != nil {
-					longRunningInstances = append(longRunningInstances, *instance)
-				}
-			}
+			instances = append(instances, reservation.Instances...)
 		}
 	}
 
-	return longRunningInstances
+	return instances, nil
+}
+
+// checkInstanceRuntime evaluates an instance against the target that applies to it among
+// the given targets. TargetIndex is the position within targets; nil means no target matched.
+func (c *Checker) checkInstanceRuntime(instance types.Instance, targets []config.Target) *Finding {
+	index, selection := c.selectTarget(instance, targets)
+	if index < 0 {
+		return nil
+	}
+
+	now := time.Now()
+	start, basis, uptimeTags := runtimeStart(instance, targets[index], now)
+	target, override, skipped := applyOverrideTags(instance, targets[index], start)
+	finding := &Finding{
+		Instance:     instance,
+		Target:       target,
+		TargetIndex:  index,
+		Selection:    selection,
+		Runtime:      now.Sub(start),
+		Decision:     DecisionSkip,
+		Override:     override,
+		RuntimeBasis: basis,
+		RuntimeStart: start,
+		UptimeTags:   uptimeTags,
+	}
+	switch {
+	case skipped:
+	case !target.RunWindows.Allow(now):
+		finding.Decision, finding.Window = DecisionAct, target.RunWindows.String()
+	case target.HasRuntimeLimit():
+		finding.Decision, finding.DueAt = evaluateRuntime(instance, target, start, c.Config.DryRun)
+	default:
+		finding.Decision = DecisionNone
+	}
+	return finding
 }
 
-// checkInstanceRuntime checks if an instance exceeds any target's runtime threshold
-func (c *Checker) checkInstanceRuntime(instance types.Instance) *types.Instance {
-	for _, target := range c.Config.Targets {
-		if !c.matchesTarget(instance, target) {
+// selectTarget returns the position of the target that applies to the instance among the
+// matching ones, and why it won over the others. -1 means no target matched.
+func (c *Checker) selectTarget(instance types.Instance, targets []config.Target) (int, string) {
+	selected, selection := -1, ""
+	for i, target := range targets {
+		if matched, _ := c.matchesTarget(instance, target); !matched {
+			continue
+		}
+		if selected < 0 {
+			selected, selection = i, "only match"
 			continue
 		}
 
-		launchTime := *instance.LaunchTime
-		runtime := time.Since(launchTime)
-		if runtime.Hours() > target.MaxRuntimeHours {
-			return &instance
+		precedes, reason := c.Config.TargetSelection.Precedes(targets[selected], target)
+		if !precedes {
+			selected = i
 		}
-		break // Found matching target, no need to check others
+		selection = reason
 	}
-	return nil
+	return selected, selection
 }
 
-// processInstances terminates instances and builds notification message
-func (c *Checker) processInstances(ctx context.Context, instances []types.Instance) string {
-	var messageBuilder strings.Builder
-	messageBuilder.WriteString(fmt.Sprintf("Found %d long-running instances:\n", len(instances)))
+// evaluateRuntime decides whether an instance matching the target, whose runtime is measured
+// from start, is due for a warning or the action, or was warned and waits for the action.
+// Unless the action is due now, it also returns when it will be. A dry run never tags the
+// warning, so it treats an instance past its limit and the grace period as warned, to show
+// what a live run would do.
+func evaluateRuntime(instance types.Instance, target config.Target, start time.Time, dryRun bool) (Decision, time.Time) {
+	runtime := time.Since(start)
+	exceeded := runtime.Hours() > target.MaxRuntimeHours
+	limit := start.Add(target.MaxRuntime())
+
+	warningThreshold := target.WarningThreshold()
+	if warningThreshold == 0 {
+		if exceeded {
+			return DecisionAct, time.Time{}
+		}
+		return DecisionNone, limit
+	}
+
+	// The action waits for the runtime limit and for the grace period after the warning
+	dueAfterWarning := func(warnAt time.Time) time.Time {
+		if due := warnAt.Add(target.GracePeriod()); due.After(limit) {
+			return due
+		}
+		return limit
+	}
 
-	for _, instance := range instances {
-		instanceID := *instance.InstanceId
-		launchTime := *instance.LaunchTime
-		runtime := time.Since(launchTime)
+	// Warnings from before the runtime start belong to an earlier run of the instance
+	warnedAt, warned := getTagTime(instance, TagWarnedAt)
+	if !warned || warnedAt.Before(start) {
+		if runtime < warningThreshold {
+			return DecisionNone, dueAfterWarning(start.Add(warningThreshold))
+		}
+		if dryRun && runtime >= target.MaxRuntime()+target.GracePeriod() {
+			return DecisionAct, time.Time{}
+		}
+		return DecisionWarn, dueAfterWarning(time.Now())
+	}
 
-		msg := fmt.Sprintf("- ID: %s, Type: %s, Runtime: %.2f hours\n", instanceID, instance.InstanceType, runtime.Hours())
-		messageBuilder.WriteString(msg)
-		slog.Info("Found long-running instance", "instance_id", instanceID, "type", instance.InstanceType, "runtime_hours", runtime.Hours())
+	if exceeded && time.Since(warnedAt) >= target.GracePeriod() {
+		return DecisionAct, time.Time{}
+	}
+	return DecisionPending, dueAfterWarning(warnedAt)
+}
 
-		if !c.Config.DryRun {
-			c.terminateInstance(ctx, instanceID, &messageBuilder)
-		} else {
-			slog.Info("DRY RUN: Would terminate instance", "instance_id", instanceID)
+// processInstances warns about or applies the target action to each due finding and
+// returns a report entry for every finding
+func (c *Checker) processInstances(ctx context.Context, findings []Finding) []InstanceReport {
+	results := make([]InstanceReport, 0, len(findings))
+	for _, finding := range findings {
+		result := newInstanceReport(finding)
+		switch finding.Decision {
+		case DecisionSkip:
+			slog.Info("Skipping exempt instance", "instance_id", result.InstanceID, "account", result.AccountID, "region", result.Region, "reason", finding.Override)
+		case DecisionWarn:
+			c.warnInstance(ctx, finding, &result)
+		case DecisionAct:
+			c.applyAction(ctx, finding, &result)
+		}
+		if finding.Override != "" && finding.Decision != DecisionSkip {
+			slog.Info("Instance tags overrode evaluation", "instance_id", result.InstanceID, "account", result.AccountID, "region", result.Region, "reason", finding.Override)
 		}
+		if len(finding.UptimeTags) > 0 {
+			c.recordUptime(ctx, finding, result)
+		}
+		results = append(results, result)
 	}
+	return results
+}
 
-	return messageBuilder.String()
+// recordUptime writes the cumulative basis bookkeeping tags on the instance. A failure only
+// loses track of the current start, so it does not fail the instance's result.
+func (c *Checker) recordUptime(ctx context.Context, finding Finding, result InstanceReport) {
+	if c.Config.DryRun {
+		slog.Info("DRY RUN: Would record instance uptime", "instance_id", result.InstanceID, "account", result.AccountID, "region", result.Region)
+		return
+	}
+	if err := tagInstance(ctx, finding.Scope.EC2Client, result.InstanceID, finding.UptimeTags); err != nil {
+		slog.Error("Failed to record instance uptime", "instance_id", result.InstanceID, "account", result.AccountID, "region", result.Region, "error", err)
+	}
 }
 
-// terminateInstance terminates a single instance and updates the message builder
-func (c *Checker) terminateInstance(ctx context.Context, instanceID string, messageBuilder *strings.Builder) {
-	slog.Info("Terminating instance", "instance_id", instanceID)
-	_, err := c.EC2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
-		InstanceIds: []string{instanceID},
-	})
+// warnInstance records the warning on the instance so the action can be deferred
+func (c *Checker) warnInstance(ctx context.Context, finding Finding, result *InstanceReport) {
+	instanceID := result.InstanceID
+	slog.Info("Warning about instance approaching runtime limit", "instance_id", instanceID, "account", result.AccountID, "region", result.Region, "runtime_hours", result.RuntimeHours, "action", result.Action, "due_at", result.DueAt, "target_index", result.TargetIndex, "selection", result.Selection)
+
+	if c.Config.DryRun {
+		slog.Info("DRY RUN: Would tag instance as warned", "instance_id", instanceID, "account", result.AccountID, "region", result.Region)
+		result.Outcome = OutcomeDryRun
+		return
+	}
+
+	start := time.Now()
+	err := tagInstance(ctx, finding.Scope.EC2Client, instanceID, map[string]string{TagWarnedAt: time.Now().UTC().Format(time.RFC3339)})
+	result.Duration = time.Since(start)
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to terminate instance %s: %v\n", instanceID, err)
-		messageBuilder.WriteString(errMsg)
-		slog.Error("Failed to terminate instance", "instance_id", instanceID, "error", err)
-	} else {
-		successMsg := fmt.Sprintf("Successfully terminated instance %s\n", instanceID)
-		messageBuilder.WriteString(successMsg)
-		slog.Info("Successfully terminated instance", "instance_id", instanceID)
+		slog.Error("Failed to tag instance as warned", "instance_id", instanceID, "account", result.AccountID, "region", result.Region, "error", err)
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+		return
 	}
+	result.Outcome = OutcomeSucceeded
 }
 
-// sendNotification sends SNS notification if configured
-func (c *Checker) sendNotification(ctx context.Context, message string) {
-	if c.Config.SNSTopicArn == "" {
-		slog.Info("SNS_TOPIC_ARN not set, skipping notification")
+// applyAction performs the target action on a single instance
+func (c *Checker) applyAction(ctx context.Context, finding Finding, result *InstanceReport) {
+	instanceID := result.InstanceID
+	action := result.Action
+	client := finding.Scope.EC2Client
+	slog.Info("Found long-running instance", "instance_id", instanceID, "account", result.AccountID, "region", result.Region, "type", result.InstanceType, "runtime_hours", result.RuntimeHours, "window", result.Window, "action", action, "target_index", result.TargetIndex, "selection", result.Selection)
+
+	if c.Config.DryRun {
+		slog.Info("DRY RUN: Would apply action to instance", "instance_id", instanceID, "account", result.AccountID, "region", result.Region, "action", action)
+		result.Outcome = OutcomeDryRun
 		return
 	}
 
-	slog.Info("Sending SNS notification...")
-	_, err := c.SNSClient.Publish(ctx, &sns.PublishInput{
-		Message:  aws.String(message),
-		TopicArn: aws.String(c.Config.SNSTopicArn),
-		Subject:  aws.String("Long-Running EC2 Instances Alert"),
-	})
+	start := time.Now()
+	var err error
+	switch action {
+	case config.ActionNotify:
+		// The notification is the action
+	case config.ActionStop, config.ActionHibernate:
+		err = stopInstance(ctx, client, instanceID, action == config.ActionHibernate)
+	case config.ActionTag:
+		// Tags from before the runtime start belong to an earlier run of the instance
+		if exceededAt, tagged := getTagTime(finding.Instance, TagExceededAt); !tagged || exceededAt.Before(finding.RuntimeStart) {
+			err = tagInstance(ctx, client, instanceID, map[string]string{TagExceededAt: time.Now().UTC().Format(time.RFC3339)})
+		}
+	default:
+		err = terminateInstance(ctx, client, instanceID)
+	}
+	result.Duration = time.Since(start)
+
 	if err != nil {
-		slog.Error("Failed to publish to SNS", "error", err)
+		slog.Error("Failed to apply action to instance", "instance_id", instanceID, "account", result.AccountID, "region", result.Region, "action", action, "error", err)
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+		return
 	}
+	slog.Info("Successfully applied action to instance", "instance_id", instanceID, "account", result.AccountID, "region", result.Region, "action", action)
+	result.Outcome = OutcomeSucceeded
+}
+
+// terminateInstance terminates a single instance
+func terminateInstance(ctx context.Context, client EC2API, instanceID string) error {
+	slog.Info("Terminating instance", "instance_id", instanceID)
+	_, err := client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	return err
 }
 
-// matchesTarget checks if an instance matches all the filter criteria in a target
-// Note: VPC ID filtering is handled at the server-side filter level via Config.VpcID
-func (c *Checker) matchesTarget(instance types.Instance, target config.Target) bool {
+// stopInstance stops a single instance, optionally hibernating it
+func stopInstance(ctx context.Context, client EC2API, instanceID string, hibernate bool) error {
+	slog.Info("Stopping instance", "instance_id", instanceID, "hibernate", hibernate)
+	_, err := client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{instanceID},
+		Hibernate:   aws.Bool(hibernate),
+	})
+	return err
+}
+
+// tagInstance writes tags on an instance in a single call
+func tagInstance(ctx context.Context, client EC2API, instanceID string, tags map[string]string) error {
+	slog.Info("Tagging instance", "instance_id", instanceID, "tags", tags)
+	input := &ec2.CreateTagsInput{Resources: []string{instanceID}}
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		input.Tags = append(input.Tags, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	_, err := client.CreateTags(ctx, input)
+	return err
+}
+
+// matchesTarget checks if an instance matches all the filter criteria in a target.
+// When it does not, the reason names the first criterion that failed.
+func (c *Checker) matchesTarget(instance types.Instance, target config.Target) (bool, string) {
+	// VPC ID is normally filtered server-side, but explain fetches instances by ID only
+	if c.Config != nil && c.Config.VpcID != "" && aws.ToString(instance.VpcId) != c.Config.VpcID {
+		return false, fmt.Sprintf("instance is in VPC %q, only VPC %q is checked", aws.ToString(instance.VpcId), c.Config.VpcID)
+	}
+
 	// Check instance type (if specified)
 	if target.InstanceType != "" && string(instance.InstanceType) != target.InstanceType {
-		return false
+		return false, fmt.Sprintf("instance type %q does not match %q", instance.InstanceType, target.InstanceType)
 	}
 
 	// Check Name tag (supports wildcard matching with *)
@@ -222,34 +713,53 @@ func (c *Checker) matchesTarget(instance types.Instance, target config.Target) b
 		instanceName := c.getInstanceName(instance)
 		matched, err := filepath.Match(target.Name, instanceName)
 		if err != nil || !matched {
-			return false
+			return false, fmt.Sprintf("Name %q does not match pattern %q", instanceName, target.Name)
 		}
 	}
 
 	// Check Tags (all specified tags must match)
-	if len(target.Tags) > 0 {
-		instanceTags := make(map[string]string)
-		for _, tag := range instance.Tags {
-			if tag.Key != nil && tag.Value != nil {
-				instanceTags[*tag.Key] = *tag.Value
-			}
+	keys := make([]string, 0, len(target.Tags))
+	for key := range target.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := getTag(instance, key)
+		if !ok {
+			return false, fmt.Sprintf("tag %q is missing, want %q", key, target.Tags[key])
 		}
-		for key, value := range target.Tags {
-			if instanceTags[key] != value {
-				return false
-			}
+		if value != target.Tags[key] {
+			return false, fmt.Sprintf("tag %q is %q, want %q", key, value, target.Tags[key])
 		}
 	}
 
-	return true
+	// Check match expressions (all must match)
+	for _, r := range target.MatchExpressions {
+		value, ok := getTag(instance, r.Key)
+		if r.Matches(value, ok) {
+			continue
+		}
+		if !ok {
+			return false, fmt.Sprintf("tag %q is missing, want %s", r.Key, r)
+		}
+		return false, fmt.Sprintf("tag %q is %q, want %s", r.Key, value, r)
+	}
+
+	return true, ""
 }
 
 // getInstanceName extracts the Name tag value from an instance
 func (c *Checker) getInstanceName(instance types.Instance) string {
+	name, _ := getTag(instance, "Name")
+	return name
+}
+
+// getTag returns the value of an instance tag and whether it is present
+func getTag(instance types.Instance, key string) (string, bool) {
 	for _, tag := range instance.Tags {
-		if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-			return *tag.Value
+		if tag.Key != nil && *tag.Key == key && tag.Value != nil {
+			return *tag.Value, true
 		}
 	}
-	return ""
+	return "", false
 }
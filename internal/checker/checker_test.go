@@ -2,6 +2,9 @@ package checker
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +20,8 @@ import (
 type MockEC2Client struct {
 	DescribeInstancesFunc  func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
 	TerminateInstancesFunc func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	StopInstancesFunc      func(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	CreateTagsFunc         func(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
 }
 
 func (m *MockEC2Client) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
@@ -27,6 +32,14 @@ func (m *MockEC2Client) TerminateInstances(ctx context.Context, params *ec2.Term
 	return m.TerminateInstancesFunc(ctx, params, optFns...)
 }
 
+func (m *MockEC2Client) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	return m.StopInstancesFunc(ctx, params, optFns...)
+}
+
+func (m *MockEC2Client) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	return m.CreateTagsFunc(ctx, params, optFns...)
+}
+
 // MockSNSClient
 type MockSNSClient struct {
 	PublishFunc func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
@@ -142,6 +155,136 @@ func TestRunCheck_DryRun(t *testing.T) {
 	chk.RunCheck(context.Background())
 }
 
+func TestRunCheck_DryRunPastGracePeriod(t *testing.T) {
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			// Past its 10 hour limit and the 2 hour grace period, never warned since dry run does not tag
+			return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: []types.Instance{{
+				InstanceId:   aws.String("i-dryrun"),
+				InstanceType: types.InstanceType("t2.micro"),
+				LaunchTime:   aws.Time(time.Now().Add(-13 * time.Hour)),
+			}}}}}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		Targets: []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 10, WarnAtPercent: 90, GracePeriodHours: 2, Action: config.ActionStop}},
+		DryRun:  true,
+	}
+	report := New(mockEC2, nil, cfg).RunCheck(context.Background())
+
+	if len(report.Instances) != 1 {
+		t.Fatalf("Expected one instance, got %+v", report.Instances)
+	}
+	if got := report.Instances[0]; got.Decision != DecisionAct || got.Outcome != OutcomeDryRun {
+		t.Errorf("Expected the dry run to act, got decision %s and outcome %s", got.Decision, got.Outcome)
+	}
+}
+
+func TestProcessInstances_Actions(t *testing.T) {
+	tests := []struct {
+		name          string
+		action        config.Action
+		wantCall      string
+		wantHibernate bool
+		wantMessage   string
+	}{
+		{name: "default terminates", action: "", wantCall: "terminate", wantMessage: "Successfully applied terminate"},
+		{name: "terminate", action: config.ActionTerminate, wantCall: "terminate", wantMessage: "Successfully applied terminate"},
+		{name: "stop", action: config.ActionStop, wantCall: "stop", wantMessage: "Successfully applied stop"},
+		{name: "hibernate", action: config.ActionHibernate, wantCall: "stop", wantHibernate: true, wantMessage: "Successfully applied hibernate"},
+		{name: "tag", action: config.ActionTag, wantCall: "tag", wantMessage: "Successfully applied tag"},
+		{name: "notify", action: config.ActionNotify, wantCall: "", wantMessage: "no action taken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			mockEC2 := &MockEC2Client{
+				TerminateInstancesFunc: func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+					calls = append(calls, "terminate")
+					return &ec2.TerminateInstancesOutput{}, nil
+				},
+				StopInstancesFunc: func(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+					calls = append(calls, "stop")
+					if aws.ToBool(params.Hibernate) != tt.wantHibernate {
+						t.Errorf("Expected Hibernate=%v, got %v", tt.wantHibernate, aws.ToBool(params.Hibernate))
+					}
+					return &ec2.StopInstancesOutput{}, nil
+				},
+				CreateTagsFunc: func(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+					calls = append(calls, "tag")
+					if len(params.Tags) != 1 || aws.ToString(params.Tags[0].Key) != TagExceededAt {
+						t.Errorf("Expected %s tag, got %v", TagExceededAt, params.Tags)
+					}
+					return &ec2.CreateTagsOutput{}, nil
+				},
+			}
+
+			chk := New(mockEC2, nil, &config.Config{DryRun: false})
+			findings := []Finding{{
+				Instance: types.Instance{InstanceId: aws.String("i-action"), InstanceType: types.InstanceType("t2.micro")},
+				Target:   config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 24, Action: tt.action},
+				Runtime:  25 * time.Hour,
+				Scope:    Scope{Region: "us-east-1", EC2Client: mockEC2},
+				Decision: DecisionAct,
+			}}
+
+			results := chk.processInstances(context.Background(), findings)
+			message := (&RunReport{Instances: results}).Message()
+
+			if tt.wantCall == "" && len(calls) != 0 {
+				t.Errorf("Expected no EC2 calls, got %v", calls)
+			}
+			if tt.wantCall != "" && (len(calls) != 1 || calls[0] != tt.wantCall) {
+				t.Errorf("Expected a single %s call, got %v", tt.wantCall, calls)
+			}
+			if len(results) != 1 || results[0].Outcome != OutcomeSucceeded {
+				t.Errorf("Expected a single succeeded result, got %+v", results)
+			}
+			if !strings.Contains(message, tt.wantMessage) {
+				t.Errorf("Expected message to contain %q, got %q", tt.wantMessage, message)
+			}
+		})
+	}
+}
+
+func TestRunCheck_TagActionKeepsExceededAt(t *testing.T) {
+	instance := types.Instance{
+		InstanceId:   aws.String("i-tag"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-25 * time.Hour)),
+	}
+	var tagCalls int
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: []types.Instance{instance}}}}, nil
+		},
+		CreateTagsFunc: func(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+			tagCalls++
+			instance.Tags = append(instance.Tags, params.Tags...)
+			return &ec2.CreateTagsOutput{}, nil
+		},
+	}
+
+	cfg := &config.Config{Targets: []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24, Action: config.ActionTag}}}
+	chk := New(mockEC2, nil, cfg)
+
+	chk.RunCheck(context.Background())
+	first, _ := getTag(instance, TagExceededAt)
+	report := chk.RunCheck(context.Background())
+
+	if tagCalls != 1 {
+		t.Errorf("Expected the tag to be written once, got %d writes", tagCalls)
+	}
+	if got, _ := getTag(instance, TagExceededAt); first == "" || got != first {
+		t.Errorf("Expected the first timestamp %q to be kept, got %q", first, got)
+	}
+	if len(report.Instances) != 1 || report.Instances[0].Outcome != OutcomeSucceeded {
+		t.Errorf("Expected the second run to succeed, got %+v", report.Instances)
+	}
+}
+
 func TestBuildFilters(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -205,7 +348,7 @@ func TestBuildFilters(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			chk := &Checker{Config: tt.config}
-			filters := chk.buildFilters()
+			filters := chk.buildFilters(tt.config.Targets)
 
 			// Always check for instance-state-name filter
 			foundRunningFilter := false
@@ -251,6 +394,318 @@ func TestBuildFilters(t *testing.T) {
 	}
 }
 
+func TestBuildQueries(t *testing.T) {
+	filterValues := func(filters []types.Filter, name string) []string {
+		for _, f := range filters {
+			if *f.Name == name {
+				return f.Values
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		targets []config.Target
+		check   func(t *testing.T, queries [][]types.Filter)
+	}{
+		{
+			name: "instance-type only targets share a query",
+			targets: []config.Target{
+				{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+				{InstanceType: "t3.small", MaxRuntimeHours: 48},
+			},
+			check: func(t *testing.T, queries [][]types.Filter) {
+				if len(queries) != 1 {
+					t.Fatalf("Expected 1 query, got %d", len(queries))
+				}
+				if got := filterValues(queries[0], "instance-type"); len(got) != 2 {
+					t.Errorf("Expected both instance types in one filter, got %v", got)
+				}
+			},
+		},
+		{
+			name: "name-only target is not restricted by another target's instance type",
+			targets: []config.Target{
+				{Name: "dev-*", MaxRuntimeHours: 24},
+				{InstanceType: "t3.small", MaxRuntimeHours: 48},
+			},
+			check: func(t *testing.T, queries [][]types.Filter) {
+				if len(queries) != 2 {
+					t.Fatalf("Expected 2 queries, got %d", len(queries))
+				}
+				if got := filterValues(queries[0], "instance-type"); got != nil {
+					t.Errorf("Expected no instance-type filter for name target, got %v", got)
+				}
+				if got := filterValues(queries[0], "tag:Name"); len(got) != 1 || got[0] != "dev-*" {
+					t.Errorf("Expected tag:Name filter dev-*, got %v", got)
+				}
+				if got := filterValues(queries[1], "tag:Name"); got != nil {
+					t.Errorf("Expected no tag:Name filter for type target, got %v", got)
+				}
+			},
+		},
+		{
+			name: "disjoint tag keys get separate queries",
+			targets: []config.Target{
+				{Tags: map[string]string{"Environment": "dev"}, MaxRuntimeHours: 24},
+				{Tags: map[string]string{"Team": "backend"}, MaxRuntimeHours: 24},
+			},
+			check: func(t *testing.T, queries [][]types.Filter) {
+				if len(queries) != 2 {
+					t.Fatalf("Expected 2 queries, got %d", len(queries))
+				}
+				if filterValues(queries[0], "tag:Team") != nil || filterValues(queries[1], "tag:Environment") != nil {
+					t.Error("Expected tag filters not to leak between queries")
+				}
+			},
+		},
+		{
+			name: "name with character class is matched client-side",
+			targets: []config.Target{
+				{Name: "dev-[0-9]*", MaxRuntimeHours: 24},
+			},
+			check: func(t *testing.T, queries [][]types.Filter) {
+				if len(queries) != 1 {
+					t.Fatalf("Expected 1 query, got %d", len(queries))
+				}
+				if got := filterValues(queries[0], "tag:Name"); got != nil {
+					t.Errorf("Expected no tag:Name filter, got %v", got)
+				}
+			},
+		},
+		{
+			name: "unfiltered target replaces all other queries",
+			targets: []config.Target{
+				{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+				{Name: "dev-[0-9]*", MaxRuntimeHours: 24},
+			},
+			check: func(t *testing.T, queries [][]types.Filter) {
+				if len(queries) != 1 {
+					t.Fatalf("Expected 1 query, got %d", len(queries))
+				}
+				if len(queries[0]) != 1 || *queries[0][0].Name != "instance-state-name" {
+					t.Errorf("Expected only the instance-state-name filter, got %d filters", len(queries[0]))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := &Checker{Config: &config.Config{Targets: tt.targets}}
+			tt.check(t, chk.buildQueries(tt.targets))
+		})
+	}
+}
+
+func TestTargetFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		expressions []config.Requirement
+		want        []string // name=values of each filter
+	}{
+		{
+			name:        "in is pushed down",
+			expressions: []config.Requirement{{Key: "Environment", Operator: config.OpIn, Values: []string{"dev", "qa"}}},
+			want:        []string{"tag:Environment=dev,qa"},
+		},
+		{
+			name:        "exists is pushed down",
+			expressions: []config.Requirement{{Key: "Owner", Operator: config.OpExists}, {Key: "Team", Operator: config.OpExists}},
+			want:        []string{"tag-key=Owner", "tag-key=Team"},
+		},
+		{
+			name:        "glob is pushed down",
+			expressions: []config.Requirement{{Key: "Team", Operator: config.OpGlob, Values: []string{"data-*", "ml-?"}}},
+			want:        []string{"tag:Team=data-*,ml-?"},
+		},
+		{
+			name:        "glob EC2 cannot express",
+			expressions: []config.Requirement{{Key: "Team", Operator: config.OpGlob, Values: []string{"data-*", "ml-[0-9]"}}},
+		},
+		{
+			name: "client-side only",
+			expressions: []config.Requirement{
+				{Key: "Environment", Operator: config.OpNotIn, Values: []string{"prod"}},
+				{Key: "Keep", Operator: config.OpDoesNotExist},
+				{Key: "Name", Operator: config.OpRegex, Values: []string{`build-\d+`}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, filter := range targetFilters(config.Target{MatchExpressions: tt.expressions}) {
+				got = append(got, aws.ToString(filter.Name)+"="+strings.Join(filter.Values, ","))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected filters %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// Targets that only differ in client-side expressions share one query
+	chk := &Checker{Config: &config.Config{}}
+	queries := chk.buildQueries([]config.Target{
+		{InstanceType: "t2.micro", MatchExpressions: []config.Requirement{{Key: "Owner", Operator: config.OpExists}, {Key: "Keep", Operator: config.OpDoesNotExist}}},
+		{InstanceType: "t3.micro", MatchExpressions: []config.Requirement{{Key: "Owner", Operator: config.OpExists}}},
+	})
+	if len(queries) != 1 {
+		t.Errorf("Expected one query, got %d", len(queries))
+	}
+}
+
+func TestFindLongRunningInstances_DeduplicatesAcrossQueries(t *testing.T) {
+	launchTime := time.Now().Add(-25 * time.Hour)
+	calls := 0
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			calls++
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{
+					Instances: []types.Instance{{
+						InstanceId:   aws.String("i-shared"),
+						InstanceType: types.InstanceType("t2.micro"),
+						LaunchTime:   &launchTime,
+						Tags: []types.Tag{
+							{Key: aws.String("Name"), Value: aws.String("dev-01")},
+						},
+					}},
+				}},
+			}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		Targets: []config.Target{
+			{Name: "dev-*", MaxRuntimeHours: 24},
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+		},
+	}
+	chk := New(mockEC2, nil, cfg)
+
+	findings, _ := chk.findLongRunningInstances(context.Background())
+
+	if calls != 2 {
+		t.Errorf("Expected 2 DescribeInstances calls, got %d", calls)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	if findings[0].Target.Name != "dev-*" {
+		t.Errorf("Expected first matching target to win, got %+v", findings[0].Target)
+	}
+}
+
+func TestFindLongRunningInstances_MultiRegion(t *testing.T) {
+	launchTime := time.Now().Add(-25 * time.Hour)
+	regionalEC2 := func(instanceID string) *MockEC2Client {
+		return &MockEC2Client{
+			DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+				return &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{
+						Instances: []types.Instance{{
+							InstanceId:   aws.String(instanceID),
+							InstanceType: types.InstanceType("t2.micro"),
+							LaunchTime:   &launchTime,
+						}},
+					}},
+				}, nil
+			},
+		}
+	}
+
+	cfg := &config.Config{
+		AWSRegions: []string{"us-east-1", "us-west-2"},
+		Targets: []config.Target{
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24, Regions: []string{"eu-west-1"}, Action: config.ActionStop},
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+		},
+	}
+	chk := New(nil, nil, cfg)
+	chk.Scopes = []Scope{
+		{Region: "us-east-1", EC2Client: regionalEC2("i-use1")},
+		{Region: "us-west-2", EC2Client: regionalEC2("i-usw2")},
+		{Region: "eu-west-1", EC2Client: regionalEC2("i-euw1")},
+	}
+
+	findings, _ := chk.findLongRunningInstances(context.Background())
+
+	if len(findings) != 3 {
+		t.Fatalf("Expected 3 findings, got %d", len(findings))
+	}
+	for i, want := range []struct {
+		instanceID string
+		region     string
+		action     config.Action
+	}{
+		{"i-use1", "us-east-1", config.ActionTerminate},
+		{"i-usw2", "us-west-2", config.ActionTerminate},
+		{"i-euw1", "eu-west-1", config.ActionStop},
+	} {
+		got := findings[i]
+		if *got.Instance.InstanceId != want.instanceID || got.Scope.Region != want.region {
+			t.Errorf("Finding %d: expected %s in %s, got %s in %s", i, want.instanceID, want.region, *got.Instance.InstanceId, got.Scope.Region)
+		}
+		if got.Target.EffectiveAction() != want.action {
+			t.Errorf("Finding %d: expected action %s, got %s", i, want.action, got.Target.EffectiveAction())
+		}
+	}
+}
+
+func TestFindLongRunningInstances_FailingAccountDoesNotAbortOthers(t *testing.T) {
+	launchTime := time.Now().Add(-25 * time.Hour)
+	failingEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return nil, errors.New("AccessDenied: not authorized to perform sts:AssumeRole")
+		},
+	}
+	healthyEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{
+					Instances: []types.Instance{{
+						InstanceId:   aws.String("i-healthy"),
+						InstanceType: types.InstanceType("t2.micro"),
+						LaunchTime:   &launchTime,
+					}},
+				}},
+			}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		AWSRegion: "us-east-1",
+		Targets:   []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
+		DryRun:    true,
+	}
+	chk := New(nil, nil, cfg)
+	chk.Scopes = []Scope{
+		{AccountID: "111111111111", Region: "us-east-1", EC2Client: failingEC2},
+		{AccountID: "222222222222", Region: "us-east-1", EC2Client: healthyEC2},
+	}
+
+	findings, scopes := chk.findLongRunningInstances(context.Background())
+
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	if len(scopes) != 2 || len(scopes[0].Errors) != 1 || len(scopes[1].Errors) != 0 {
+		t.Errorf("Expected the error to be reported for the failing account only, got %+v", scopes)
+	}
+	if findings[0].Scope.AccountID != "222222222222" {
+		t.Errorf("Expected finding labelled with account 222222222222, got %q", findings[0].Scope.AccountID)
+	}
+
+	report := &RunReport{Scopes: scopes, Instances: chk.processInstances(context.Background(), findings)}
+	message := report.Message()
+	if !strings.Contains(message, "Account: 222222222222") {
+		t.Errorf("Expected message to include the account ID, got %q", message)
+	}
+}
+
 func TestCheckInstanceRuntime(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -300,24 +755,218 @@ func TestCheckInstanceRuntime(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &config.Config{Targets: tt.targets}
 			chk := &Checker{Config: cfg}
-			result := chk.checkInstanceRuntime(tt.instance)
+			result := chk.checkInstanceRuntime(tt.instance, tt.targets)
 
-			if tt.expectedResult && result == nil {
+			flagged := result != nil && result.Decision == DecisionAct
+			if tt.expectedResult && !flagged {
 				t.Error("Expected instance to be flagged as long-running, but got nil")
 			}
-			if !tt.expectedResult && result != nil {
+			if !tt.expectedResult && flagged {
 				t.Error("Expected instance not to be flagged, but got result")
 			}
 		})
 	}
 }
 
+func TestCheckInstanceRuntime_Selection(t *testing.T) {
+	instance := types.Instance{
+		InstanceId:   aws.String("i-123"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-10 * time.Hour)),
+		Tags:         []types.Tag{{Key: aws.String("Team"), Value: aws.String("ci")}},
+	}
+	typeOnly := config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 24}
+	typeAndTags := config.Target{InstanceType: "t2.micro", Tags: map[string]string{"Team": "ci"}, MaxRuntimeHours: 8}
+	other := config.Target{InstanceType: "m5.large", MaxRuntimeHours: 1}
+
+	tests := []struct {
+		name          string
+		selection     config.TargetSelection
+		targets       []config.Target
+		wantIndex     int
+		wantSelection string
+		wantDecision  Decision
+	}{
+		{name: "only match", targets: []config.Target{other, typeOnly}, wantIndex: 1, wantSelection: "only match", wantDecision: DecisionNone},
+		{name: "first match by default", targets: []config.Target{typeOnly, typeAndTags}, wantIndex: 0, wantSelection: "earlier in the config", wantDecision: DecisionNone},
+		{name: "most specific match", selection: config.SelectMostSpecific, targets: []config.Target{typeOnly, typeAndTags, other}, wantIndex: 1, wantSelection: "more specific", wantDecision: DecisionAct},
+		{
+			name:          "priority over specificity",
+			selection:     config.SelectMostSpecific,
+			targets:       []config.Target{typeAndTags, {InstanceType: "t2.micro", MaxRuntimeHours: 24, Priority: 1}},
+			wantIndex:     1,
+			wantSelection: "higher priority",
+			wantDecision:  DecisionNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := &Checker{Config: &config.Config{TargetSelection: tt.selection, Targets: tt.targets}}
+			finding := chk.checkInstanceRuntime(instance, tt.targets)
+			if finding == nil {
+				t.Fatal("Expected a finding, got nil")
+			}
+			if finding.TargetIndex != tt.wantIndex || finding.Selection != tt.wantSelection || finding.Decision != tt.wantDecision {
+				t.Errorf("Expected targets[%d] (%s) with %s, got targets[%d] (%s) with %s",
+					tt.wantIndex, tt.wantSelection, tt.wantDecision, finding.TargetIndex, finding.Selection, finding.Decision)
+			}
+		})
+	}
+}
+
+func TestCheckInstanceRuntime_RunWindows(t *testing.T) {
+	open := config.RunWindows{{Start: "00:00", End: "24:00"}}
+	closed := config.RunWindows{{Days: []string{time.Now().UTC().AddDate(0, 0, 2).Weekday().String()}, Start: "00:00", End: "24:00"}}
+	instance := func(tags ...types.Tag) types.Instance {
+		return types.Instance{
+			InstanceId:   aws.String("i-dev"),
+			InstanceType: types.InstanceType("t3.large"),
+			LaunchTime:   aws.Time(time.Now().Add(-2 * time.Hour)),
+			Tags:         tags,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		target       config.Target
+		instance     types.Instance
+		wantDecision Decision
+		wantWindow   bool
+	}{
+		{name: "inside the windows", target: config.Target{InstanceType: "t3.large", RunWindows: open}, instance: instance(), wantDecision: DecisionNone},
+		{name: "outside the windows", target: config.Target{InstanceType: "t3.large", RunWindows: closed, Action: config.ActionStop}, instance: instance(), wantDecision: DecisionAct, wantWindow: true},
+		{name: "outside the windows within the runtime limit", target: config.Target{InstanceType: "t3.large", MaxRuntimeHours: 24, RunWindows: closed}, instance: instance(), wantDecision: DecisionAct, wantWindow: true},
+		{name: "inside the windows past the runtime limit", target: config.Target{InstanceType: "t3.large", MaxRuntimeHours: 1, RunWindows: open}, instance: instance(), wantDecision: DecisionAct},
+		{
+			name:         "exempt outside the windows",
+			target:       config.Target{InstanceType: "t3.large", RunWindows: closed},
+			instance:     instance(types.Tag{Key: aws.String(TagExempt), Value: aws.String("true")}),
+			wantDecision: DecisionSkip,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := New(nil, nil, &config.Config{})
+			finding := chk.checkInstanceRuntime(tt.instance, []config.Target{tt.target})
+			if finding == nil {
+				t.Fatal("Expected a finding, got nil")
+			}
+			if finding.Decision != tt.wantDecision || (finding.Window != "") != tt.wantWindow {
+				t.Errorf("Expected %s (outside windows: %v), got %s (window %q)", tt.wantDecision, tt.wantWindow, finding.Decision, finding.Window)
+			}
+		})
+	}
+}
+
+func TestCheckInstanceRuntime_Warnings(t *testing.T) {
+	target := config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 10, WarnAtPercent: 90, GracePeriodHours: 2}
+	instance := func(runtime time.Duration, warnedAgo time.Duration) types.Instance {
+		inst := types.Instance{
+			InstanceId:   aws.String("i-warn"),
+			InstanceType: types.InstanceType("t2.micro"),
+			LaunchTime:   aws.Time(time.Now().Add(-runtime)),
+		}
+		if warnedAgo >= 0 {
+			inst.Tags = []types.Tag{{
+				Key:   aws.String(TagWarnedAt),
+				Value: aws.String(time.Now().Add(-warnedAgo).UTC().Format(time.RFC3339)),
+			}}
+		}
+		return inst
+	}
+
+	tests := []struct {
+		name        string
+		instance    types.Instance
+		wantFinding bool
+		wantWarning bool
+		wantPending bool
+		dryRun      bool
+	}{
+		{name: "below warning threshold", instance: instance(8*time.Hour, -1)},
+		{name: "first detection warns", instance: instance(9*time.Hour+30*time.Minute, -1), wantFinding: true, wantWarning: true},
+		{name: "first detection past limit still warns", instance: instance(12*time.Hour, -1), wantFinding: true, wantWarning: true},
+		{name: "warned but limit not reached", instance: instance(9*time.Hour+30*time.Minute, 3*time.Hour), wantPending: true},
+		{name: "warned but grace period not elapsed", instance: instance(11*time.Hour, time.Hour), wantPending: true},
+		{name: "warned and grace period elapsed", instance: instance(11*time.Hour, 3*time.Hour), wantFinding: true},
+		{name: "dry run within the grace period warns", instance: instance(11*time.Hour, -1), dryRun: true, wantFinding: true, wantWarning: true},
+		{name: "dry run past the grace period acts", instance: instance(12*time.Hour+time.Minute, -1), dryRun: true, wantFinding: true},
+		{name: "warning from before last launch is ignored", instance: instance(9*time.Hour+30*time.Minute, 20*time.Hour), wantFinding: true, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := &Checker{Config: &config.Config{Targets: []config.Target{target}, DryRun: tt.dryRun}}
+			finding := chk.checkInstanceRuntime(tt.instance, []config.Target{target})
+			if finding == nil {
+				t.Fatal("Expected the instance to match the target")
+			}
+
+			due := finding.Decision == DecisionAct || finding.Decision == DecisionWarn
+			if due != tt.wantFinding {
+				t.Fatalf("Expected due=%v, got %+v", tt.wantFinding, finding)
+			}
+			if warning := finding.Decision == DecisionWarn; warning != tt.wantWarning {
+				t.Errorf("Expected warning=%v, got decision %s", tt.wantWarning, finding.Decision)
+			}
+			if finding.Decision == DecisionWarn && time.Until(finding.DueAt) < target.GracePeriod()-time.Minute {
+				t.Errorf("Expected action to be due after the grace period, got %v", finding.DueAt)
+			}
+			if pending := finding.Decision == DecisionPending; pending != tt.wantPending {
+				t.Errorf("Expected pending=%v, got decision %s", tt.wantPending, finding.Decision)
+			}
+			if finding.Decision == DecisionPending && !finding.DueAt.After(time.Now()) {
+				t.Errorf("Expected the pending action to be due later, got %v", finding.DueAt)
+			}
+		})
+	}
+}
+
+func TestProcessInstances_WarningTagsInstance(t *testing.T) {
+	var tagged []types.Tag
+	mockEC2 := &MockEC2Client{
+		CreateTagsFunc: func(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+			tagged = append(tagged, params.Tags...)
+			return &ec2.CreateTagsOutput{}, nil
+		},
+		TerminateInstancesFunc: func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+			t.Error("TerminateInstances should not be called for a warning")
+			return nil, nil
+		},
+	}
+
+	chk := New(mockEC2, nil, &config.Config{})
+	results := chk.processInstances(context.Background(), []Finding{{
+		Instance: types.Instance{InstanceId: aws.String("i-warn"), InstanceType: types.InstanceType("t2.micro")},
+		Target:   config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 10, WarnAtPercent: 90},
+		Runtime:  9 * time.Hour,
+		Scope:    Scope{Region: "us-east-1", EC2Client: mockEC2},
+		Decision: DecisionWarn,
+		DueAt:    time.Now().Add(time.Hour),
+	}})
+	message := (&RunReport{Instances: results}).Message()
+
+	if len(tagged) != 1 || aws.ToString(tagged[0].Key) != TagWarnedAt {
+		t.Errorf("Expected a single %s tag, got %v", TagWarnedAt, tagged)
+	}
+	if len(results) != 1 || results[0].Outcome != OutcomeSucceeded {
+		t.Errorf("Expected a single succeeded result, got %+v", results)
+	}
+	if !strings.Contains(message, "approaching their runtime limit") || strings.Contains(message, "long-running instances") {
+		t.Errorf("Expected only a warning section in message, got %q", message)
+	}
+}
+
 func TestMatchesTarget(t *testing.T) {
 	tests := []struct {
 		name     string
 		instance types.Instance
 		target   config.Target
+		vpcID    string
 		expected bool
+		reason   string
 	}{
 		{
 			name: "matches instance type",
@@ -340,6 +989,7 @@ func TestMatchesTarget(t *testing.T) {
 				MaxRuntimeHours: 24,
 			},
 			expected: false,
+			reason:   `instance type "t3.micro" does not match "t2.micro"`,
 		},
 		{
 			name: "matches name tag with wildcard",
@@ -368,6 +1018,7 @@ func TestMatchesTarget(t *testing.T) {
 				MaxRuntimeHours: 24,
 			},
 			expected: false,
+			reason:   `Name "prod-instance-01" does not match pattern "dev-*"`,
 		},
 		{
 			name: "matches tags",
@@ -402,6 +1053,7 @@ func TestMatchesTarget(t *testing.T) {
 				MaxRuntimeHours: 24,
 			},
 			expected: false,
+			reason:   `tag "Environment" is "prod", want "dev"`,
 		},
 		{
 			name: "matches all criteria",
@@ -422,40 +1074,133 @@ func TestMatchesTarget(t *testing.T) {
 			},
 			expected: true,
 		},
+		{
+			name: "missing tag",
+			instance: types.Instance{
+				InstanceType: types.InstanceType("t2.micro"),
+			},
+			target: config.Target{
+				Tags:            map[string]string{"Team": "backend"},
+				MaxRuntimeHours: 24,
+			},
+			expected: false,
+			reason:   `tag "Team" is missing, want "backend"`,
+		},
+		{
+			name: "matches expressions",
+			instance: types.Instance{
+				InstanceType: types.InstanceType("t2.micro"),
+				Tags: []types.Tag{
+					{Key: aws.String("Name"), Value: aws.String("build-42")},
+					{Key: aws.String("Owner"), Value: aws.String("alice")},
+					{Key: aws.String("Environment"), Value: aws.String("dev")},
+				},
+			},
+			target: config.Target{
+				MatchExpressions: []config.Requirement{
+					{Key: "Owner", Operator: config.OpExists},
+					{Key: "Environment", Operator: config.OpNotIn, Values: []string{"prod", "staging"}},
+					{Key: "Name", Operator: config.OpRegex, Values: []string{`build-\d+`}},
+					{Key: "Keep", Operator: config.OpDoesNotExist},
+				},
+				MaxRuntimeHours: 24,
+			},
+			expected: true,
+		},
+		{
+			name: "does not match expression",
+			instance: types.Instance{
+				InstanceType: types.InstanceType("t2.micro"),
+				Tags: []types.Tag{
+					{Key: aws.String("Environment"), Value: aws.String("prod")},
+				},
+			},
+			target: config.Target{
+				MatchExpressions: []config.Requirement{
+					{Key: "Environment", Operator: config.OpNotIn, Values: []string{"prod", "staging"}},
+				},
+				MaxRuntimeHours: 24,
+			},
+			expected: false,
+			reason:   `tag "Environment" is "prod", want Environment NotIn [prod staging]`,
+		},
+		{
+			name: "missing tag for expression",
+			instance: types.Instance{
+				InstanceType: types.InstanceType("t2.micro"),
+			},
+			target: config.Target{
+				MatchExpressions: []config.Requirement{{Key: "Owner", Operator: config.OpExists}},
+				MaxRuntimeHours:  24,
+			},
+			expected: false,
+			reason:   `tag "Owner" is missing, want Owner Exists`,
+		},
+		{
+			name: "outside the configured VPC",
+			instance: types.Instance{
+				InstanceType: types.InstanceType("t2.micro"),
+				VpcId:        aws.String("vpc-other"),
+			},
+			target: config.Target{
+				InstanceType:    "t2.micro",
+				MaxRuntimeHours: 24,
+			},
+			vpcID:    "vpc-12345678",
+			expected: false,
+			reason:   `instance is in VPC "vpc-other", only VPC "vpc-12345678" is checked`,
+		},
+		{
+			name: "inside the configured VPC",
+			instance: types.Instance{
+				InstanceType: types.InstanceType("t2.micro"),
+				VpcId:        aws.String("vpc-12345678"),
+			},
+			target: config.Target{
+				InstanceType:    "t2.micro",
+				MaxRuntimeHours: 24,
+			},
+			vpcID:    "vpc-12345678",
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			chk := &Checker{}
-			result := chk.matchesTarget(tt.instance, tt.target)
+			chk := &Checker{Config: &config.Config{VpcID: tt.vpcID}}
+			result, reason := chk.matchesTarget(tt.instance, tt.target)
 			if result != tt.expected {
-				t.Errorf("Expected %v, got %v", tt.expected, result)
+				t.Errorf("Expected %v, got %v (%s)", tt.expected, result, reason)
+			}
+			if !strings.Contains(reason, tt.reason) || (tt.expected && reason != "") {
+				t.Errorf("Expected reason containing %q, got %q", tt.reason, reason)
 			}
 		})
 	}
 }
 
 func TestSendNotification(t *testing.T) {
+	report := &RunReport{Instances: []InstanceReport{{InstanceID: "i-1", Region: "us-east-1", Decision: DecisionAct, Action: config.ActionTerminate}}}
+
 	tests := []struct {
 		name          string
 		config        *config.Config
-		message       string
 		expectPublish bool
 	}{
 		{
 			name: "sends notification when SNS topic is configured",
 			config: &config.Config{
 				SNSTopicArn: "arn:aws:sns:us-east-1:123456789012:mytopic",
+				Targets:     []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
 			},
-			message:       "Test message",
 			expectPublish: true,
 		},
 		{
 			name: "skips notification when SNS topic is not configured",
 			config: &config.Config{
 				SNSTopicArn: "",
+				Targets:     []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
 			},
-			message:       "Test message",
 			expectPublish: false,
 		},
 	}
@@ -469,25 +1214,24 @@ func TestSendNotification(t *testing.T) {
 					if *params.TopicArn != tt.config.SNSTopicArn {
 						t.Errorf("Expected topic ARN %s, got %s", tt.config.SNSTopicArn, *params.TopicArn)
 					}
-					if *params.Message != tt.message {
-						t.Errorf("Expected message %s, got %s", tt.message, *params.Message)
+					if *params.Message != report.Message() || *params.Subject != NotificationSubject {
+						t.Errorf("Expected the report message, got %s: %s", *params.Subject, *params.Message)
 					}
 					return &sns.PublishOutput{}, nil
 				},
 			}
 
-			chk := &Checker{
-				SNSClient: mockSNS,
-				Config:    tt.config,
-			}
-
-			chk.sendNotification(context.Background(), tt.message)
+			// A checker built without New publishes with its SNS client like one built with New
+			for _, chk := range []*Checker{New(nil, mockSNS, tt.config), {SNSClient: mockSNS, Config: tt.config}} {
+				publishCalled = false
+				chk.sendNotifications(context.Background(), report)
 
-			if tt.expectPublish && !publishCalled {
-				t.Error("Expected Publish to be called, but it wasn't")
-			}
-			if !tt.expectPublish && publishCalled {
-				t.Error("Expected Publish not to be called, but it was")
+				if tt.expectPublish && !publishCalled {
+					t.Error("Expected Publish to be called, but it wasn't")
+				}
+				if !tt.expectPublish && publishCalled {
+					t.Error("Expected Publish not to be called, but it was")
+				}
 			}
 		})
 	}
@@ -556,3 +1300,29 @@ func TestRunCheck_WithVpcID(t *testing.T) {
 	chk := New(mockEC2, mockSNS, cfg)
 	chk.RunCheck(context.Background())
 }
+
+func TestSetTargets(t *testing.T) {
+	var describedTypes []string
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			for _, filter := range params.Filters {
+				if aws.ToString(filter.Name) == "instance-type" {
+					describedTypes = append(describedTypes, filter.Values...)
+				}
+			}
+			return &ec2.DescribeInstancesOutput{}, nil
+		},
+	}
+
+	cfg := &config.Config{Targets: []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}}}
+	chk := New(mockEC2, nil, cfg)
+	chk.SetTargets([]config.Target{{InstanceType: "m5.large", MaxRuntimeHours: 8}})
+	chk.RunCheck(context.Background())
+
+	if len(describedTypes) != 1 || describedTypes[0] != "m5.large" {
+		t.Errorf("Expected the reloaded target to be queried, got %v", describedTypes)
+	}
+	if cfg.Targets[0].InstanceType != "t2.micro" {
+		t.Errorf("Expected the original config to be left untouched, got %+v", cfg.Targets)
+	}
+}
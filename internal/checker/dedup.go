@@ -0,0 +1,174 @@
+package checker
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+)
+
+// notificationState remembers what each notifier was last told about each instance, so that
+// only new findings and changed outcomes are notified. It is kept in memory and, with a
+// path, in a file read at startup and written after every run.
+type notificationState struct {
+	path string
+
+	// Notifiers holds the notified instances by notifier name, then by instanceKey
+	Notifiers map[string]map[string]notifiedInstance `json:"notifiers"`
+}
+
+// notifiedInstance is what a notifier was last told about an instance
+type notifiedInstance struct {
+	AccountID  string        `json:"accountId,omitempty"`
+	Region     string        `json:"region"`
+	Action     config.Action `json:"action"`
+	Decision   Decision      `json:"decision"`
+	Outcome    Outcome       `json:"outcome"`
+	NotifiedAt time.Time     `json:"notifiedAt"`
+}
+
+// loadNotificationState returns the state saved at the path, if any. A file that cannot be
+// read is logged and the state starts empty, so every outstanding instance is notified again.
+func loadNotificationState(path string) *notificationState {
+	state := &notificationState{path: path, Notifiers: make(map[string]map[string]notifiedInstance)}
+	if path == "" {
+		return state
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state
+	}
+	if err == nil {
+		err = json.Unmarshal(data, state)
+	}
+	if err != nil {
+		slog.Error("Failed to load notification state, notifying every outstanding instance", "path", path, "error", err)
+		state.Notifiers = nil
+	}
+	if state.Notifiers == nil {
+		state.Notifiers = make(map[string]map[string]notifiedInstance)
+	}
+	return state
+}
+
+// instanceKey identifies an instance across accounts and regions
+func instanceKey(accountID, region, instanceID string) string {
+	return accountID + "/" + region + "/" + instanceID
+}
+
+// unreported returns the report restricted to the warned, acted on and skipped instances the
+// notifier was not yet told about with the same action, decision and outcome
+func (s *notificationState) unreported(name string, report *RunReport) *RunReport {
+	pending := *report
+	pending.Instances = nil
+	for _, instance := range report.Instances {
+		if instance.Decision == DecisionNone {
+			continue
+		}
+		notified, ok := s.Notifiers[name][instanceKey(instance.AccountID, instance.Region, instance.InstanceID)]
+		if ok && notified.Action == instance.Action && notified.Decision == instance.Decision && notified.Outcome == instance.Outcome {
+			continue
+		}
+		pending.Instances = append(pending.Instances, instance)
+	}
+	return &pending
+}
+
+// record remembers the instances of the report as notified to the notifier, except those
+// whose notification failed, which are notified again by the next run
+func (s *notificationState) record(name string, report *RunReport, failed []InstanceReport, at time.Time) {
+	notified := s.Notifiers[name]
+	if notified == nil {
+		notified = make(map[string]notifiedInstance)
+		s.Notifiers[name] = notified
+	}
+
+	failedKeys := make(map[string]bool, len(failed))
+	for _, instance := range failed {
+		failedKeys[instanceKey(instance.AccountID, instance.Region, instance.InstanceID)] = true
+	}
+	for _, instance := range report.Instances {
+		key := instanceKey(instance.AccountID, instance.Region, instance.InstanceID)
+		if instance.Decision == DecisionNone || failedKeys[key] {
+			continue
+		}
+		notified[key] = notifiedInstance{
+			AccountID:  instance.AccountID,
+			Region:     instance.Region,
+			Action:     instance.Action,
+			Decision:   instance.Decision,
+			Outcome:    instance.Outcome,
+			NotifiedAt: at,
+		}
+	}
+}
+
+// forget drops the instances that are no longer warned about, waiting for their action,
+// acted on or skipped, such as stopped instances or those back within their limit, so they
+// are notified again if they come back. Instances in scopes that failed to scan are kept,
+// since they were not seen.
+func (s *notificationState) forget(report *RunReport) {
+	outstanding := make(map[string]bool)
+	for _, instance := range report.Instances {
+		if instance.Decision != DecisionNone {
+			outstanding[instanceKey(instance.AccountID, instance.Region, instance.InstanceID)] = true
+		}
+	}
+	failedScopes := make(map[string]bool)
+	for _, scope := range report.Scopes {
+		if len(scope.Errors) > 0 {
+			failedScopes[instanceKey(scope.AccountID, scope.Region, "")] = true
+		}
+	}
+
+	for name, notified := range s.Notifiers {
+		for key, instance := range notified {
+			if !outstanding[key] && !failedScopes[instanceKey(instance.AccountID, instance.Region, "")] {
+				delete(notified, key)
+			}
+		}
+		if len(notified) == 0 {
+			delete(s.Notifiers, name)
+		}
+	}
+}
+
+// save writes the state to its file, if it has one. The file is replaced atomically, so an
+// interrupted write never leaves it truncated. A failure is only logged, the state is still
+// kept in memory.
+func (s *notificationState) save() {
+	if s.path == "" {
+		return
+	}
+	if err := s.write(); err != nil {
+		slog.Error("Failed to save notification state", "path", s.path, "error", err)
+	}
+}
+
+// write replaces the state file through a temporary file in the same directory
+func (s *notificationState) write() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,245 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+func TestRunCheck_NotifiesChangesOnly(t *testing.T) {
+	instance := func(id string) types.Instance {
+		return types.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: types.InstanceType("t2.micro"),
+			LaunchTime:   aws.Time(time.Now().Add(-25 * time.Hour)),
+		}
+	}
+
+	var running []types.Instance
+	var stopErr error
+	var stops int
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: running}}}, nil
+		},
+		StopInstancesFunc: func(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+			stops++
+			return &ec2.StopInstancesOutput{}, stopErr
+		},
+	}
+
+	type published struct{ subject, message string }
+	var messages []published
+	var publishErr error
+	mockSNS := &MockSNSClient{
+		PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+			if publishErr != nil {
+				return nil, publishErr
+			}
+			messages = append(messages, published{aws.ToString(params.Subject), aws.ToString(params.Message)})
+			return &sns.PublishOutput{}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		AWSRegion:             "us-east-1",
+		Targets:               []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24, Action: config.ActionStop}},
+		SNSTopicArn:           "arn:aws:sns:us-east-1:123456789012:mytopic",
+		NotifyChangesOnly:     true,
+		NotificationStatePath: filepath.Join(t.TempDir(), "state.json"),
+	}
+	chk := New(mockEC2, mockSNS, cfg)
+
+	steps := []struct {
+		name      string
+		running   []string
+		stopErr   error
+		failSend  bool
+		digest    bool
+		restart   bool
+		want      []string // Instances in the message, none expected when empty
+		wantNotIn []string
+	}{
+		{name: "first run", running: []string{"i-1"}, want: []string{"i-1"}},
+		{name: "unchanged", running: []string{"i-1"}},
+		{name: "new instance", running: []string{"i-1", "i-2"}, want: []string{"i-2"}, wantNotIn: []string{"i-1"}},
+		{name: "changed outcome", running: []string{"i-1", "i-2"}, stopErr: errors.New("UnauthorizedOperation"), want: []string{"i-1", "i-2"}},
+		{name: "digest", running: []string{"i-1", "i-2"}, stopErr: errors.New("UnauthorizedOperation"), digest: true, want: []string{"i-1", "i-2"}},
+		{name: "after digest", running: []string{"i-1", "i-2"}, stopErr: errors.New("UnauthorizedOperation")},
+		{name: "failed notification", running: []string{"i-1", "i-2", "i-3"}, stopErr: errors.New("UnauthorizedOperation"), failSend: true},
+		{name: "retried notification", running: []string{"i-1", "i-2", "i-3"}, stopErr: errors.New("UnauthorizedOperation"), want: []string{"i-3"}, wantNotIn: []string{"i-1"}},
+		{name: "stopped instance", running: []string{"i-2", "i-3"}, stopErr: errors.New("UnauthorizedOperation")},
+		{name: "instance back", running: []string{"i-1", "i-2", "i-3"}, stopErr: errors.New("UnauthorizedOperation"), want: []string{"i-1"}, wantNotIn: []string{"i-2"}},
+		{name: "restart", running: []string{"i-1", "i-2", "i-3"}, stopErr: errors.New("UnauthorizedOperation"), restart: true},
+	}
+
+	for _, step := range steps {
+		running = nil
+		for _, id := range step.running {
+			running = append(running, instance(id))
+		}
+		stopErr = step.stopErr
+		publishErr = nil
+		if step.failSend {
+			publishErr = errors.New("throttled")
+		}
+		if step.restart {
+			chk = New(mockEC2, mockSNS, cfg)
+		}
+
+		messages = nil
+		stops = 0
+		if step.digest {
+			chk.RunDigest(context.Background())
+			if stops != 0 {
+				t.Errorf("%s: expected the digest not to act, got %d stops", step.name, stops)
+			}
+		} else {
+			chk.RunCheck(context.Background())
+		}
+
+		if len(step.want) == 0 {
+			if len(messages) != 0 {
+				t.Errorf("%s: expected no notification, got:\n%s", step.name, messages[0].message)
+			}
+			continue
+		}
+		if len(messages) != 1 {
+			t.Errorf("%s: expected one notification, got %d", step.name, len(messages))
+			continue
+		}
+		for _, id := range step.want {
+			if !strings.Contains(messages[0].message, id) {
+				t.Errorf("%s: expected %s in the notification, got:\n%s", step.name, id, messages[0].message)
+			}
+		}
+		for _, id := range step.wantNotIn {
+			if strings.Contains(messages[0].message, id) {
+				t.Errorf("%s: expected no %s in the notification, got:\n%s", step.name, id, messages[0].message)
+			}
+		}
+		wantSubject := NotificationSubject
+		if step.digest {
+			wantSubject = DigestSubject
+		}
+		if messages[0].subject != wantSubject {
+			t.Errorf("%s: expected subject %q, got %q", step.name, wantSubject, messages[0].subject)
+		}
+	}
+}
+
+func TestRunDigest_PendingInstances(t *testing.T) {
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			// Past its limit, but warned an hour into a two hour grace period
+			return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: []types.Instance{{
+				InstanceId:   aws.String("i-pending"),
+				InstanceType: types.InstanceType("t2.micro"),
+				LaunchTime:   aws.Time(time.Now().Add(-25 * time.Hour)),
+				Tags:         []types.Tag{{Key: aws.String(TagWarnedAt), Value: aws.String(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))}},
+			}}}}}, nil
+		},
+		CreateTagsFunc: func(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+			t.Error("Expected no tags to be written")
+			return &ec2.CreateTagsOutput{}, nil
+		},
+	}
+	var messages []string
+	mockSNS := &MockSNSClient{
+		PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+			messages = append(messages, aws.ToString(params.Message))
+			return &sns.PublishOutput{}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		AWSRegion:   "us-east-1",
+		Targets:     []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24, WarnAtPercent: 90, GracePeriodHours: 2, Action: config.ActionStop}},
+		SNSTopicArn: "arn:aws:sns:us-east-1:123456789012:mytopic",
+	}
+	chk := New(mockEC2, mockSNS, cfg)
+
+	// The warning was already notified, so a check has nothing new to say
+	if report := chk.RunCheck(context.Background()); report.Count(DecisionPending) != 1 || len(messages) != 0 {
+		t.Fatalf("Expected a pending instance and no notification, got %+v and %q", report.Instances, messages)
+	}
+
+	chk.RunDigest(context.Background())
+	if len(messages) != 1 || !strings.Contains(messages[0], "1 warned instances are waiting for their action") || !strings.Contains(messages[0], "i-pending") {
+		t.Errorf("Expected the digest to list the pending instance, got %q", messages)
+	}
+}
+
+func TestNotificationState_Forget(t *testing.T) {
+	state := loadNotificationState("")
+	state.record("slack", &RunReport{Instances: []InstanceReport{
+		{InstanceID: "i-1", Region: "us-east-1", Decision: DecisionAct},
+		{InstanceID: "i-2", Region: "us-east-1", Decision: DecisionWarn},
+		{InstanceID: "i-3", AccountID: "111111111111", Region: "eu-west-1", Decision: DecisionAct},
+		{InstanceID: "i-4", Region: "us-east-1", Decision: DecisionNone},
+		{InstanceID: "i-5", Region: "us-east-1", Decision: DecisionWarn},
+	}}, []InstanceReport{{InstanceID: "i-2", Region: "us-east-1"}}, time.Now())
+
+	if len(state.Notifiers["slack"]) != 3 {
+		t.Fatalf("Expected the notified instances only, got %v", state.Notifiers["slack"])
+	}
+
+	// i-1 is back within its limit, i-3 was not seen since its scope failed and i-5 waits
+	// for its action after the warning
+	state.forget(&RunReport{
+		Scopes: []ScopeReport{{Region: "us-east-1"}, {AccountID: "111111111111", Region: "eu-west-1", Errors: []string{"AccessDenied"}}},
+		Instances: []InstanceReport{
+			{InstanceID: "i-1", Region: "us-east-1", Decision: DecisionNone},
+			{InstanceID: "i-5", Region: "us-east-1", Decision: DecisionPending},
+		},
+	})
+	if _, ok := state.Notifiers["slack"][instanceKey("", "us-east-1", "i-1")]; ok {
+		t.Error("Expected the instance back within its limit to be forgotten")
+	}
+	if _, ok := state.Notifiers["slack"][instanceKey("111111111111", "eu-west-1", "i-3")]; !ok {
+		t.Error("Expected the instance in the failed scope to be kept")
+	}
+	if _, ok := state.Notifiers["slack"][instanceKey("", "us-east-1", "i-5")]; !ok {
+		t.Error("Expected the warned instance waiting for its action to be kept")
+	}
+}
+
+func TestLoadNotificationState(t *testing.T) {
+	dir := t.TempDir()
+
+	if state := loadNotificationState(filepath.Join(dir, "missing.json")); len(state.Notifiers) != 0 {
+		t.Errorf("Expected an empty state without a file, got %v", state.Notifiers)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte(`{"notifiers": {"sns": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if state := loadNotificationState(corrupt); len(state.Notifiers) != 0 {
+		t.Errorf("Expected an empty state for a corrupt file, got %v", state.Notifiers)
+	}
+
+	path := filepath.Join(dir, "state.json")
+	state := loadNotificationState(path)
+	state.record("sns", &RunReport{Instances: []InstanceReport{{InstanceID: "i-1", Region: "us-east-1", Action: config.ActionStop, Decision: DecisionAct, Outcome: OutcomeDryRun}}}, nil, time.Now())
+	state.save()
+
+	loaded := loadNotificationState(path)
+	if got := loaded.Notifiers["sns"][instanceKey("", "us-east-1", "i-1")]; got.Outcome != OutcomeDryRun || got.Action != config.ActionStop {
+		t.Errorf("Expected the saved instance to be loaded, got %+v", loaded.Notifiers)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected no temporary file left behind, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,128 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ErrInstanceNotFound is returned by Explain when no scope has the instance
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// Explanation describes how the checker evaluates a single instance
+type Explanation struct {
+	Instance types.Instance
+	Scope    Scope
+	Runtime  time.Duration
+
+	// NotChecked is why the instance is never evaluated regardless of the targets, if it is not
+	NotChecked string
+	// Targets holds the outcome of matching the instance against every target, in order
+	Targets []TargetMatch
+	// Finding is the evaluation against the target selected for the instance, nil when none matches
+	Finding *Finding
+	// DueAt is when the action will be taken, zero when no target matches or the instance is exempt
+	DueAt time.Time
+}
+
+// TargetMatch is the outcome of matching an instance against one target
+type TargetMatch struct {
+	TargetIndex int
+	Matched     bool
+	// Reason is why the target does not match, or does not apply although it matches
+	Reason string
+}
+
+// Explain fetches the instance from whichever scope has it and evaluates it against every
+// target, explaining why each target does or does not match. It never modifies the instance.
+func (c *Checker) Explain(ctx context.Context, instanceID string) (*Explanation, error) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	instance, scope, err := c.findInstance(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{
+		Instance: instance,
+		Scope:    scope,
+		Runtime:  time.Since(aws.ToTime(instance.LaunchTime)),
+	}
+	if instance.State != nil && instance.State.Name != types.InstanceStateNameRunning {
+		explanation.NotChecked = fmt.Sprintf("instance is %s, only running instances are checked", instance.State.Name)
+	}
+
+	// Targets outside the instance's region never apply, so they take no part in the selection
+	var applicable []config.Target
+	var applicableIndexes []int
+	for i, target := range c.Config.Targets {
+		match := TargetMatch{TargetIndex: i}
+		match.Matched, match.Reason = c.matchesTarget(instance, target)
+		if match.Matched && !c.Config.TargetInRegion(target, scope.Region) {
+			match.Matched = false
+			match.Reason = fmt.Sprintf("target does not apply in region %s", scope.Region)
+		}
+		if match.Matched {
+			applicable = append(applicable, target)
+			applicableIndexes = append(applicableIndexes, i)
+		}
+		explanation.Targets = append(explanation.Targets, match)
+	}
+	if explanation.NotChecked != "" || len(applicable) == 0 {
+		return explanation, nil
+	}
+
+	finding := c.checkInstanceRuntime(instance, applicable)
+	finding.TargetIndex = applicableIndexes[finding.TargetIndex]
+	finding.Scope = scope
+	explanation.Finding = finding
+	if finding.Decision != DecisionSkip {
+		explanation.DueAt = actionDueAt(*finding)
+	}
+
+	selected := c.Config.Targets[finding.TargetIndex]
+	for _, i := range applicableIndexes {
+		if i == finding.TargetIndex {
+			continue
+		}
+		// Precedes compares in config order
+		var reason string
+		if i < finding.TargetIndex {
+			_, reason = c.Config.TargetSelection.Precedes(c.Config.Targets[i], selected)
+		} else {
+			_, reason = c.Config.TargetSelection.Precedes(selected, c.Config.Targets[i])
+		}
+		explanation.Targets[i].Reason = fmt.Sprintf("targets[%d] takes precedence (%s)", finding.TargetIndex, reason)
+	}
+	return explanation, nil
+}
+
+// findInstance describes the instance in every scope until one has it. Scopes that fail
+// are only reported if no other scope has the instance.
+func (c *Checker) findInstance(ctx context.Context, instanceID string) (types.Instance, Scope, error) {
+	// Filtering by ID instead of passing InstanceIds returns nothing rather than an error
+	// in scopes without the instance
+	filters := []types.Filter{{Name: aws.String("instance-id"), Values: []string{instanceID}}}
+
+	var errs []error
+	for _, scope := range c.scopes() {
+		instances, err := describeInstances(ctx, scope.EC2Client, filters)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", location(scope.AccountID, scope.Region), err))
+			continue
+		}
+		if len(instances) > 0 {
+			return instances[0], scope, nil
+		}
+	}
+
+	err := fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	return types.Instance{}, Scope{}, errors.Join(append([]error{err}, errs...)...)
+}
@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestExplain(t *testing.T) {
+	instance := types.Instance{
+		InstanceId:   aws.String("i-build"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-30 * time.Hour)),
+		State:        &types.InstanceState{Name: types.InstanceStateNameRunning},
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("build-01")},
+			{Key: aws.String("Team"), Value: aws.String("ci")},
+		},
+	}
+
+	// Only DescribeInstances is mocked, any other call panics
+	scope := func(region string, instances ...types.Instance) Scope {
+		return Scope{Region: region, EC2Client: &MockEC2Client{
+			DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+				if len(params.Filters) != 1 || aws.ToString(params.Filters[0].Name) != "instance-id" {
+					t.Errorf("Expected a single instance-id filter, got %+v", params.Filters)
+				}
+				return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: instances}}}, nil
+			},
+		}}
+	}
+
+	cfg := &config.Config{
+		AWSRegions: []string{"us-east-1", "eu-west-1"},
+		Targets: []config.Target{
+			{InstanceType: "m5.large", MaxRuntimeHours: 24},
+			{Name: "build-*", MaxRuntimeHours: 24, Regions: []string{"us-east-1"}},
+			{Tags: map[string]string{"Team": "web"}, MaxRuntimeHours: 24},
+			{Name: "build-*", MaxRuntimeHours: 24, Action: config.ActionStop},
+			{InstanceType: "t2.micro", MaxRuntimeHours: 12},
+		},
+	}
+	chk := New(nil, nil, cfg)
+	chk.Scopes = []Scope{scope("us-east-1"), scope("eu-west-1", instance)}
+
+	explanation, err := chk.Explain(context.Background(), "i-build")
+	if err != nil {
+		t.Fatalf("Explain() failed: %v", err)
+	}
+
+	if explanation.Scope.Region != "eu-west-1" {
+		t.Errorf("Expected the instance to be found in eu-west-1, got %s", explanation.Scope.Region)
+	}
+	want := []struct {
+		matched bool
+		reason  string
+	}{
+		{false, `instance type "t2.micro" does not match "m5.large"`},
+		{false, "target does not apply in region eu-west-1"},
+		{false, `tag "Team" is "ci", want "web"`},
+		{true, ""},
+		{true, "targets[3] takes precedence (earlier in the config)"},
+	}
+	if len(explanation.Targets) != len(want) {
+		t.Fatalf("Expected %d target matches, got %+v", len(want), explanation.Targets)
+	}
+	for i, w := range want {
+		match := explanation.Targets[i]
+		if match.TargetIndex != i || match.Matched != w.matched || match.Reason != w.reason {
+			t.Errorf("targets[%d]: expected %v %q, got %+v", i, w.matched, w.reason, match)
+		}
+	}
+
+	finding := explanation.Finding
+	if finding == nil || finding.TargetIndex != 3 || finding.Decision != DecisionAct || finding.Target.EffectiveAction() != config.ActionStop {
+		t.Errorf("Expected targets[3] to stop the instance, got %+v", finding)
+	}
+	if explanation.DueAt.After(time.Now()) {
+		t.Errorf("Expected the action to be due, got %v", explanation.DueAt)
+	}
+}
+
+func TestExplain_NotChecked(t *testing.T) {
+	stopped := types.Instance{
+		InstanceId:   aws.String("i-stopped"),
+		InstanceType: types.InstanceType("t2.micro"),
+		LaunchTime:   aws.Time(time.Now().Add(-30 * time.Hour)),
+		State:        &types.InstanceState{Name: types.InstanceStateNameStopped},
+	}
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			if params.Filters[0].Values[0] != "i-stopped" {
+				return &ec2.DescribeInstancesOutput{}, nil
+			}
+			return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: []types.Instance{stopped}}}}, nil
+		},
+	}
+	cfg := &config.Config{
+		AWSRegion: "us-east-1",
+		Targets:   []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
+	}
+	chk := New(mockEC2, nil, cfg)
+
+	explanation, err := chk.Explain(context.Background(), "i-stopped")
+	if err != nil {
+		t.Fatalf("Explain() failed: %v", err)
+	}
+	if !strings.Contains(explanation.NotChecked, "instance is stopped") || explanation.Finding != nil {
+		t.Errorf("Expected a stopped instance not to be checked, got %q and %+v", explanation.NotChecked, explanation.Finding)
+	}
+	if len(explanation.Targets) != 1 || !explanation.Targets[0].Matched {
+		t.Errorf("Expected the target matches to be explained anyway, got %+v", explanation.Targets)
+	}
+
+	_, err = chk.Explain(context.Background(), "i-missing")
+	if !errors.Is(err, ErrInstanceNotFound) {
+		t.Errorf("Expected ErrInstanceNotFound, got %v", err)
+	}
+}
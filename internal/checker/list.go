@@ -0,0 +1,85 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ListEntry is an instance that matched a target, with when the checker would act on it
+type ListEntry struct {
+	AccountID      string              `json:"accountId,omitempty"`
+	Region         string              `json:"region"`
+	InstanceID     string              `json:"instanceId"`
+	InstanceType   string              `json:"instanceType"`
+	Name           string              `json:"name"`
+	TargetIndex    int                 `json:"targetIndex"`
+	RuntimeHours   float64             `json:"runtimeHours"`
+	RuntimeBasis   config.RuntimeBasis `json:"runtimeBasis"`
+	ThresholdHours float64             `json:"thresholdHours"`
+	Action         config.Action       `json:"action"`
+	Decision       Decision            `json:"decision"`
+
+	// HoursRemaining is the time left until the action, zero when it is due and nil
+	// when the instance is exempt or never due
+	HoursRemaining *float64 `json:"hoursRemaining"`
+	Override       string   `json:"override,omitempty"`
+	Window         string   `json:"window,omitempty"`
+}
+
+// List scans all scopes like RunCheck and returns every instance matching a target,
+// without warning, acting or notifying
+func (c *Checker) List(ctx context.Context) ([]ListEntry, []ScopeReport) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	findings, scopes := c.findLongRunningInstances(ctx)
+	entries := make([]ListEntry, 0, len(findings))
+	for _, finding := range findings {
+		name, _ := getTag(finding.Instance, "Name")
+		entry := ListEntry{
+			AccountID:      finding.Scope.AccountID,
+			Region:         finding.Scope.Region,
+			InstanceID:     aws.ToString(finding.Instance.InstanceId),
+			InstanceType:   string(finding.Instance.InstanceType),
+			Name:           name,
+			TargetIndex:    finding.TargetIndex,
+			RuntimeHours:   finding.Runtime.Hours(),
+			RuntimeBasis:   finding.RuntimeBasis,
+			ThresholdHours: finding.Target.MaxRuntimeHours,
+			Action:         finding.Target.EffectiveAction(),
+			Decision:       finding.Decision,
+			Override:       finding.Override,
+			Window:         finding.Window,
+		}
+		if finding.Decision != DecisionSkip {
+			if dueAt := actionDueAt(finding); !dueAt.IsZero() {
+				remaining := max(time.Until(dueAt), 0).Hours()
+				entry.HoursRemaining = &remaining
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scopes
+}
+
+// actionDueAt returns when the target's action will be taken on the finding's instance:
+// when its run windows close or its runtime is due, whichever comes first. It returns the
+// zero time when neither will happen.
+func actionDueAt(finding Finding) time.Time {
+	now := time.Now()
+	if finding.Decision == DecisionAct {
+		return now
+	}
+	closesAt, closes := finding.Target.RunWindows.ClosesAt(now)
+	if !finding.Target.HasRuntimeLimit() {
+		return closesAt
+	}
+	if closes && closesAt.Before(finding.DueAt) {
+		return closesAt
+	}
+	return finding.DueAt
+}
@@ -0,0 +1,165 @@
+package checker
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestList(t *testing.T) {
+	instance := func(id, instanceType string, runtime time.Duration, tags ...types.Tag) types.Instance {
+		return types.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: types.InstanceType(instanceType),
+			LaunchTime:   aws.Time(time.Now().Add(-runtime)),
+			Tags:         tags,
+		}
+	}
+
+	// Only DescribeInstances is mocked, any other call panics
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{
+					Instances: []types.Instance{
+						instance("i-due", "t2.micro", 30*time.Hour, types.Tag{Key: aws.String("Name"), Value: aws.String("build")}),
+						instance("i-fresh", "m5.large", 2*time.Hour),
+						instance("i-exempt", "t2.micro", 30*time.Hour, types.Tag{Key: aws.String(TagExempt), Value: aws.String("true")}),
+						instance("i-other", "c5.large", 30*time.Hour),
+					},
+				}},
+			}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		AWSRegion: "us-east-1",
+		DryRun:    false,
+		Targets: []config.Target{
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+			{InstanceType: "m5.large", MaxRuntimeHours: 10, Action: config.ActionStop},
+		},
+	}
+	entries, scopes := New(mockEC2, nil, cfg).List(context.Background())
+
+	if len(scopes) != 1 || scopes[0].Scanned != 4 {
+		t.Fatalf("Expected one scope with 4 scanned instances, got %+v", scopes)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", entries)
+	}
+
+	want := map[string]struct {
+		targetIndex int
+		action      config.Action
+		decision    Decision
+		remaining   float64 // Negative for exempt
+	}{
+		"i-due":    {0, config.ActionTerminate, DecisionAct, 0},
+		"i-fresh":  {1, config.ActionStop, DecisionNone, 8},
+		"i-exempt": {0, config.ActionTerminate, DecisionSkip, -1},
+	}
+	for _, entry := range entries {
+		w, ok := want[entry.InstanceID]
+		if !ok {
+			t.Errorf("Unexpected entry %+v", entry)
+			continue
+		}
+		if entry.TargetIndex != w.targetIndex || entry.Action != w.action || entry.Decision != w.decision {
+			t.Errorf("%s: expected target %d, %s, %s, got %+v", entry.InstanceID, w.targetIndex, w.action, w.decision, entry)
+		}
+		switch {
+		case w.remaining < 0 && entry.HoursRemaining != nil:
+			t.Errorf("%s: expected no hours remaining, got %v", entry.InstanceID, *entry.HoursRemaining)
+		case w.remaining >= 0 && (entry.HoursRemaining == nil || math.Abs(*entry.HoursRemaining-w.remaining) > 0.01):
+			t.Errorf("%s: expected %v hours remaining, got %v", entry.InstanceID, w.remaining, entry.HoursRemaining)
+		}
+	}
+	if entries[0].Name != "build" {
+		t.Errorf("Expected the Name tag, got %q", entries[0].Name)
+	}
+}
+
+func TestActionDueAt(t *testing.T) {
+	now := time.Now()
+	launched := now.Add(-10 * time.Hour)
+	warnedTag := func(at time.Time) []types.Tag {
+		return []types.Tag{{Key: aws.String(TagWarnedAt), Value: aws.String(at.UTC().Format(time.RFC3339))}}
+	}
+
+	tests := []struct {
+		name   string
+		tags   []types.Tag
+		target config.Target
+		want   time.Time
+	}{
+		{
+			name:   "without warnings at the runtime limit",
+			target: config.Target{MaxRuntimeHours: 24},
+			want:   launched.Add(24 * time.Hour),
+		},
+		{
+			name:   "warning still ahead",
+			target: config.Target{MaxRuntimeHours: 20, WarnAtPercent: 60, GracePeriodHours: 12},
+			want:   launched.Add(24 * time.Hour),
+		},
+		{
+			name:   "grace period shorter than the remaining runtime",
+			target: config.Target{MaxRuntimeHours: 20, WarnAtPercent: 60, GracePeriodHours: 1},
+			want:   launched.Add(20 * time.Hour),
+		},
+		{
+			name:   "past the warning threshold but not warned yet",
+			target: config.Target{MaxRuntimeHours: 12, WarnAtPercent: 50, GracePeriodHours: 4},
+			want:   now.Add(4 * time.Hour),
+		},
+		{
+			name:   "warned",
+			tags:   warnedTag(now.Add(-time.Hour)),
+			target: config.Target{MaxRuntimeHours: 10, WarnAtPercent: 50, GracePeriodHours: 4},
+			want:   now.Add(3 * time.Hour),
+		},
+		{
+			name:   "outside run windows",
+			target: config.Target{MaxRuntimeHours: 24, RunWindows: config.RunWindows{{Days: []string{now.UTC().AddDate(0, 0, 2).Weekday().String()}, Start: "00:00", End: "24:00"}}},
+			want:   now,
+		},
+		{
+			name:   "run windows that never close",
+			target: config.Target{RunWindows: config.RunWindows{{Start: "00:00", End: "24:00"}}},
+		},
+		{
+			name:   "warned and past the grace period",
+			tags:   warnedTag(now.Add(-5 * time.Hour)),
+			target: config.Target{MaxRuntimeHours: 8, WarnAtPercent: 50, GracePeriodHours: 4},
+			want:   now,
+		},
+		{
+			name:   "warning from an earlier launch",
+			tags:   warnedTag(launched.Add(-time.Hour)),
+			target: config.Target{MaxRuntimeHours: 10, WarnAtPercent: 50, GracePeriodHours: 4},
+			want:   now.Add(4 * time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.Instance{InstanceId: aws.String("i-1"), LaunchTime: &launched, Tags: tt.tags}
+			finding := Finding{Instance: instance, Target: tt.target, Decision: DecisionNone}
+			if tt.target.HasRuntimeLimit() {
+				finding.Decision, finding.DueAt = evaluateRuntime(instance, tt.target, launched, false)
+			}
+			got := actionDueAt(finding)
+			if got.Sub(tt.want).Abs() > time.Minute {
+				t.Errorf("actionDueAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
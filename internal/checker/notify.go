@@ -0,0 +1,208 @@
+package checker
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/notifier"
+)
+
+// NotificationSubject is the subject of every run notification without a subject template
+const NotificationSubject = "Long-Running EC2 Instances Alert"
+
+// DigestSubject is the subject of every digest without a subject template
+const DigestSubject = "Long-Running EC2 Instances Digest"
+
+// sendNotifications sends every notifier the part of the report routed to it by the
+// targets, skipping notifiers with no outstanding instance in their part. Unless the report
+// is a digest, which is neither filtered nor recorded, instances already notified with the
+// same outcome are left out when only changes are notified. Instances of targets with their
+// own template are sent in a message per template, and those with an owner to the owner
+// when the notifier notifies owners. A notifier that fails is only logged, so the others
+// are still notified.
+func (c *Checker) sendNotifications(ctx context.Context, report *RunReport) {
+	notifiers := c.notifiers()
+	if len(notifiers) == 0 {
+		slog.Info("No notifiers configured, skipping notification")
+		return
+	}
+
+	for _, n := range notifiers {
+		routed := c.routedReport(report, n.Name())
+		if c.notified != nil && !report.Digest {
+			routed = c.notified.unreported(n.Name(), routed)
+		}
+		if routed.Outstanding() == 0 {
+			slog.Info("No instances routed to notifier, skipping notification", "notifier", n.Name())
+			continue
+		}
+
+		settings := c.notifierConfig(n.Name())
+		rest := routed
+		if owners, ok := n.(notifier.OwnerNotifier); ok && settings.NotifyOwners {
+			rest = c.notifyOwners(ctx, owners, settings, routed)
+		}
+		failed := c.notify(ctx, settings, rest, "", n.Notify)
+		if c.notified != nil && !report.Digest {
+			c.notified.record(n.Name(), routed, failed, report.FinishedAt)
+		}
+	}
+}
+
+// notifiers returns the checker's notifiers or, when it has none, an SNS notifier publishing
+// to SNS_TOPIC_ARN with SNSClient
+func (c *Checker) notifiers() []notifier.Notifier {
+	if len(c.Notifiers) > 0 || c.SNSClient == nil || c.Config.SNSTopicArn == "" {
+		return c.Notifiers
+	}
+	return []notifier.Notifier{notifier.NewSNS(config.SNSNotifierName, c.SNSClient, c.Config.SNSTopicArn)}
+}
+
+// notifyOwners sends the owner of every instance in the report a personal message about
+// their instances. It returns the rest of the report for the notifier's destination: the
+// instances without an owner and those whose owner could not be notified.
+func (c *Checker) notifyOwners(ctx context.Context, n notifier.OwnerNotifier, settings config.Notifier, report *RunReport) *RunReport {
+	rest := *report
+	rest.Instances = nil
+	var owners []string
+	instances := make(map[string][]InstanceReport)
+	for _, instance := range report.Instances {
+		owner := strings.TrimSpace(instance.Tags[c.Config.OwnerTag])
+		if owner == "" {
+			rest.Instances = append(rest.Instances, instance)
+			continue
+		}
+		if _, ok := instances[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		instances[owner] = append(instances[owner], instance)
+	}
+
+	for _, owner := range owners {
+		// Scan errors are left to the destination, they concern no owner in particular
+		personal := &RunReport{StartedAt: report.StartedAt, FinishedAt: report.FinishedAt, DryRun: report.DryRun, Instances: instances[owner]}
+		failed := c.notify(ctx, settings, personal, owner, func(ctx context.Context, message notifier.Message) error {
+			return n.NotifyOwner(ctx, owner, message)
+		})
+		rest.Instances = append(rest.Instances, failed...)
+	}
+	return &rest
+}
+
+// notify sends the report with send in a message per template and recipients, skipping
+// those with no outstanding instance, and returns the instances of the messages that could
+// not be sent. The owner, if any, is who the messages are addressed to.
+func (c *Checker) notify(ctx context.Context, settings config.Notifier, report *RunReport, owner string, send func(context.Context, notifier.Message) error) []InstanceReport {
+	var failed []InstanceReport
+	// Recipients only apply to email to the notifier's destination
+	byRecipients := settings.Type == config.NotifierSMTP && owner == ""
+	for _, part := range c.messageParts(report, settings.Template, byRecipients) {
+		if part.report.Outstanding() == 0 {
+			continue
+		}
+
+		slog.Info("Sending notification...", "notifier", settings.Name, "owner", owner, "instances", len(part.report.Instances))
+		data := newNotificationData(part.report, c.Config.Targets, settings.EffectiveFormat())
+		data.Owner = owner
+		message := renderNotification(data, part.template, settings)
+		message.To = part.recipients
+		if err := send(ctx, message); err != nil {
+			slog.Error("Failed to send notification", "notifier", settings.Name, "owner", owner, "error", err)
+			failed = append(failed, part.report.Instances...)
+		}
+	}
+	return failed
+}
+
+// routedReport returns the report restricted to the instances whose target notifies the
+// named notifier. Scan errors concern every target, so they are kept. Pending instances were
+// notified when they were warned, so only digests include them.
+func (c *Checker) routedReport(report *RunReport, name string) *RunReport {
+	routed := *report
+	routed.Instances = nil
+	for _, instance := range report.Instances {
+		if instance.Decision == DecisionPending && !report.Digest {
+			continue
+		}
+		if instance.TargetIndex < len(c.Config.Targets) && c.Config.Targets[instance.TargetIndex].NotifiesTo(name) {
+			routed.Instances = append(routed.Instances, instance)
+		}
+	}
+	return &routed
+}
+
+// notifierConfig returns the config of the named notifier, only holding the name for
+// notifiers not built from the config
+func (c *Checker) notifierConfig(name string) config.Notifier {
+	for _, n := range c.Config.AllNotifiers() {
+		if n.Name == name {
+			return n
+		}
+	}
+	return config.Notifier{Name: name}
+}
+
+// messagePart is the part of a report sent in one message
+type messagePart struct {
+	template   config.MessageTemplate
+	recipients []string
+	report     *RunReport
+}
+
+// messageParts splits the report by the template its instances are rendered with, their
+// target's falling back to the notifier's, and optionally by their target's recipients.
+// Parts are in order of their first instance and each keeps the scan errors.
+func (c *Checker) messageParts(report *RunReport, notifierTemplate config.MessageTemplate, byRecipients bool) []messagePart {
+	type key struct {
+		template   config.MessageTemplate
+		recipients string
+	}
+
+	var parts []messagePart
+	indexes := make(map[key]int)
+	for _, instance := range report.Instances {
+		part := messagePart{template: notifierTemplate}
+		if instance.TargetIndex < len(c.Config.Targets) {
+			target := c.Config.Targets[instance.TargetIndex]
+			part.template = target.Template.Or(notifierTemplate)
+			if byRecipients {
+				part.recipients = target.Recipients
+			}
+		}
+
+		k := key{template: part.template, recipients: strings.Join(part.recipients, ",")}
+		i, ok := indexes[k]
+		if !ok {
+			i = len(parts)
+			indexes[k] = i
+			partReport := *report
+			partReport.Instances = nil
+			part.report = &partReport
+			parts = append(parts, part)
+		}
+		parts[i].report.Instances = append(parts[i].report.Instances, instance)
+	}
+	return parts
+}
+
+// renderNotification renders the message with the template, adding an HTML body for email.
+// A template that fails to render is logged and replaced by the built-in one, so the
+// notification is still sent.
+func renderNotification(data NotificationData, tmpl config.MessageTemplate, settings config.Notifier) notifier.Message {
+	subject, body, err := renderMessage(data, tmpl)
+	if err != nil {
+		slog.Error("Failed to render notification template, using the built-in one", "notifier", settings.Name, "error", err)
+		subject, body, _ = renderMessage(data, config.MessageTemplate{})
+	}
+	message := notifier.Message{Subject: subject, Body: body, Format: data.Format, Data: data.RunReport}
+
+	if settings.Type == config.NotifierSMTP {
+		if message.HTML, err = renderHTML(data, tmpl.HTML); err != nil {
+			slog.Error("Failed to render notification template, using the built-in one", "notifier", settings.Name, "error", err)
+			message.HTML, _ = renderHTML(data, "")
+		}
+	}
+	return message
+}
@@ -0,0 +1,176 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/notifier"
+)
+
+// MockNotifier records the messages it is sent
+type MockNotifier struct {
+	name     string
+	err      error
+	messages []notifier.Message
+}
+
+func (m *MockNotifier) Name() string {
+	return m.name
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, message notifier.Message) error {
+	m.messages = append(m.messages, message)
+	return m.err
+}
+
+// MockOwnerNotifier records the messages it is sent, and those to owners by owner
+type MockOwnerNotifier struct {
+	MockNotifier
+	failOwner string
+	owners    map[string][]notifier.Message
+}
+
+func (m *MockOwnerNotifier) NotifyOwner(ctx context.Context, owner string, message notifier.Message) error {
+	if owner == m.failOwner {
+		return errors.New("users_not_found")
+	}
+	if m.owners == nil {
+		m.owners = make(map[string][]notifier.Message)
+	}
+	m.owners[owner] = append(m.owners[owner], message)
+	return nil
+}
+
+func TestSendNotifications_Routing(t *testing.T) {
+	cfg := &config.Config{Targets: []config.Target{
+		{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+		{InstanceType: "g5.xlarge", MaxRuntimeHours: 8, Notify: []string{"slack-ml"}},
+		{InstanceType: "m5.large", MaxRuntimeHours: 48, Notify: []string{"slack-ml", "webhook"}},
+	}}
+	report := &RunReport{
+		Scopes: []ScopeReport{{Region: "eu-west-1", Errors: []string{"UnauthorizedOperation"}}},
+		Instances: []InstanceReport{
+			{InstanceID: "i-micro", TargetIndex: 0, Decision: DecisionAct},
+			{InstanceID: "i-gpu", TargetIndex: 1, Decision: DecisionWarn},
+			{InstanceID: "i-large", TargetIndex: 2, Decision: DecisionNone},
+		},
+	}
+
+	failing := &MockNotifier{name: "webhook", err: errors.New("unexpected status 500")}
+	slack := &MockNotifier{name: "slack-ml"}
+	teams := &MockNotifier{name: "teams"}
+	chk := New(nil, nil, cfg)
+	chk.Notifiers = []notifier.Notifier{failing, slack, teams}
+
+	chk.sendNotifications(context.Background(), report)
+
+	tests := []struct {
+		notifier *MockNotifier
+		want     []string
+	}{
+		// The webhook fails first, which does not stop the others
+		{notifier: failing, want: []string{"i-micro", "i-large"}},
+		{notifier: slack, want: []string{"i-micro", "i-gpu", "i-large"}},
+		{notifier: teams, want: []string{"i-micro"}},
+	}
+	for _, tt := range tests {
+		if len(tt.notifier.messages) != 1 {
+			t.Fatalf("%s: expected one message, got %d", tt.notifier.name, len(tt.notifier.messages))
+		}
+		message := tt.notifier.messages[0]
+		routed, ok := message.Data.(*RunReport)
+		if !ok {
+			t.Fatalf("%s: expected the routed report as data, got %T", tt.notifier.name, message.Data)
+		}
+		var got []string
+		for _, instance := range routed.Instances {
+			got = append(got, instance.InstanceID)
+		}
+		if !slices.Equal(got, tt.want) || message.Body != routed.Message() || len(routed.Scopes) != 1 {
+			t.Errorf("%s: expected instances %v with the scan error, got %v", tt.notifier.name, tt.want, got)
+		}
+	}
+}
+
+func TestSendNotifications_SkipsNotifiersWithNothingDue(t *testing.T) {
+	cfg := &config.Config{Targets: []config.Target{
+		{InstanceType: "t2.micro", MaxRuntimeHours: 24, Notify: []string{"slack"}},
+		{InstanceType: "g5.xlarge", MaxRuntimeHours: 8, Notify: []string{"teams"}},
+	}}
+	report := &RunReport{Instances: []InstanceReport{
+		{InstanceID: "i-micro", TargetIndex: 0, Decision: DecisionAct},
+		{InstanceID: "i-gpu", TargetIndex: 1, Decision: DecisionSkip},
+	}}
+
+	slack := &MockNotifier{name: "slack"}
+	teams := &MockNotifier{name: "teams"}
+	chk := New(nil, nil, cfg)
+	chk.Notifiers = []notifier.Notifier{slack, teams}
+
+	chk.sendNotifications(context.Background(), report)
+
+	if len(slack.messages) != 1 || len(teams.messages) != 0 {
+		t.Errorf("Expected only slack to be notified, got %d and %d messages", len(slack.messages), len(teams.messages))
+	}
+}
+
+func TestSendNotifications_Owners(t *testing.T) {
+	cfg := &config.Config{
+		OwnerTag: "CreatedBy",
+		Notifiers: config.Notifiers{
+			{Name: "mail", Type: config.NotifierSMTP, NotifyOwners: true},
+			{Name: "chat", Type: config.NotifierSlack},
+		},
+		Targets: []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
+	}
+	report := &RunReport{
+		Scopes: []ScopeReport{{Region: "eu-west-1", Errors: []string{"UnauthorizedOperation"}}},
+		Instances: []InstanceReport{
+			{InstanceID: "i-alice-1", Tags: map[string]string{"CreatedBy": "alice@example.com"}, Decision: DecisionAct},
+			{InstanceID: "i-unowned", Tags: map[string]string{"Owner": "bob@example.com"}, Decision: DecisionAct},
+			{InstanceID: "i-alice-2", Tags: map[string]string{"CreatedBy": " alice@example.com "}, Decision: DecisionWarn},
+			{InstanceID: "i-gone", Tags: map[string]string{"CreatedBy": "gone@example.com"}, Decision: DecisionAct},
+			{InstanceID: "i-carol", Tags: map[string]string{"CreatedBy": "carol@example.com"}, Decision: DecisionNone},
+		},
+	}
+
+	mail := &MockOwnerNotifier{MockNotifier: MockNotifier{name: "mail"}, failOwner: "gone@example.com"}
+	chat := &MockOwnerNotifier{MockNotifier: MockNotifier{name: "chat"}}
+	chk := New(nil, nil, cfg)
+	chk.Notifiers = []notifier.Notifier{mail, chat}
+
+	chk.sendNotifications(context.Background(), report)
+
+	instanceIDs := func(message notifier.Message) []string {
+		var ids []string
+		for _, instance := range message.Data.(*RunReport).Instances {
+			ids = append(ids, instance.InstanceID)
+		}
+		return ids
+	}
+
+	if len(mail.owners) != 1 || len(mail.owners["alice@example.com"]) != 1 {
+		t.Fatalf("Expected one personal message to alice only, got %v", mail.owners)
+	}
+	personal := mail.owners["alice@example.com"][0]
+	if got := instanceIDs(personal); !slices.Equal(got, []string{"i-alice-1", "i-alice-2"}) || strings.Contains(personal.Body, "scans failed") {
+		t.Errorf("Expected alice's instances without scan errors, got %v:\n%s", got, personal.Body)
+	}
+
+	// The unowned instance and the one whose owner could not be reached go to the destination
+	if len(mail.messages) != 1 {
+		t.Fatalf("Expected one message to the destination, got %d", len(mail.messages))
+	}
+	if got := instanceIDs(mail.messages[0]); !slices.Equal(got, []string{"i-unowned", "i-gone"}) || !strings.Contains(mail.messages[0].Body, "scans failed") {
+		t.Errorf("Expected the unowned and unreachable instances with scan errors, got %v:\n%s", got, mail.messages[0].Body)
+	}
+
+	// Notifiers that do not notify owners get every instance
+	if len(chat.owners) != 0 || len(chat.messages) != 1 || len(instanceIDs(chat.messages[0])) != 5 {
+		t.Errorf("Expected chat to get one message with every instance, got %v and %d messages", chat.owners, len(chat.messages))
+	}
+}
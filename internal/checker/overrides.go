@@ -0,0 +1,65 @@
+package checker
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Tags instance owners can set to protect or extend their own instances
+const (
+	// TagExempt set to "true" makes the checker leave the instance alone
+	TagExempt = "runtime-checker/exempt"
+
+	// TagMaxRuntimeHours overrides the matching target's MaxRuntimeHours
+	TagMaxRuntimeHours = "runtime-checker/max-runtime-hours"
+
+	// TagExpiresAt (RFC3339) replaces the runtime threshold with a fixed deadline
+	TagExpiresAt = "runtime-checker/expires-at"
+)
+
+// applyOverrideTags returns the target adjusted by the instance's override tags, for an
+// instance whose runtime is measured from start, and the reason for the adjustment, empty
+// when no tag applied. When skipped is set, the instance must not be warned about or acted on.
+func applyOverrideTags(instance types.Instance, target config.Target, start time.Time) (adjusted config.Target, reason string, skipped bool) {
+	var reasons []string
+
+	if value, ok := getTag(instance, TagExempt); ok {
+		exempt, err := strconv.ParseBool(value)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("ignored %s=%q: not a boolean", TagExempt, value))
+		} else if exempt {
+			return target, fmt.Sprintf("exempt by %s tag", TagExempt), true
+		}
+	}
+
+	if value, ok := getTag(instance, TagMaxRuntimeHours); ok {
+		maxRuntimeHours, err := strconv.ParseFloat(value, 64)
+		// NaN fails every comparison and Inf never expires, so neither is a usable limit
+		if err != nil || maxRuntimeHours <= 0 || math.IsNaN(maxRuntimeHours) || math.IsInf(maxRuntimeHours, 0) {
+			reasons = append(reasons, fmt.Sprintf("ignored %s=%q: not a positive number", TagMaxRuntimeHours, value))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("max runtime %g hours from %s tag (target: %g hours)", maxRuntimeHours, TagMaxRuntimeHours, target.MaxRuntimeHours))
+			target.MaxRuntimeHours = maxRuntimeHours
+		}
+	}
+
+	if value, ok := getTag(instance, TagExpiresAt); ok {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("ignored %s=%q: not an RFC3339 time", TagExpiresAt, value))
+		} else {
+			// Expressed as a runtime so warnings and the action follow the usual rules
+			reasons = append(reasons, fmt.Sprintf("expires at %s from %s tag", expiresAt.UTC().Format(time.RFC3339), TagExpiresAt))
+			target.MaxRuntimeHours = expiresAt.Sub(start).Hours()
+		}
+	}
+
+	return target, strings.Join(reasons, "; "), false
+}
@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestCheckInstanceRuntime_OverrideTags(t *testing.T) {
+	target := config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 24}
+	instance := func(runtime time.Duration, tags map[string]string) types.Instance {
+		inst := types.Instance{
+			InstanceId:   aws.String("i-tagged"),
+			InstanceType: types.InstanceType("t2.micro"),
+			LaunchTime:   aws.Time(time.Now().Add(-runtime)),
+		}
+		for key, value := range tags {
+			inst.Tags = append(inst.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		return inst
+	}
+
+	tests := []struct {
+		name         string
+		instance     types.Instance
+		wantFinding  bool
+		wantOverride bool
+		wantSkipped  bool
+		wantReason   string
+	}{
+		{
+			name:        "no override tags",
+			instance:    instance(25*time.Hour, nil),
+			wantFinding: true,
+		},
+		{
+			name:         "exempt instance is skipped",
+			instance:     instance(25*time.Hour, map[string]string{TagExempt: "true"}),
+			wantOverride: true,
+			wantSkipped:  true,
+			wantReason:   "exempt",
+		},
+		{
+			name:        "exempt=false has no effect",
+			instance:    instance(25*time.Hour, map[string]string{TagExempt: "false"}),
+			wantFinding: true,
+		},
+		{
+			name:         "max runtime tag extends threshold",
+			instance:     instance(25*time.Hour, map[string]string{TagMaxRuntimeHours: "48"}),
+			wantOverride: true,
+			wantReason:   "max runtime 48 hours",
+		},
+		{
+			name:         "max runtime tag lowers threshold",
+			instance:     instance(5*time.Hour, map[string]string{TagMaxRuntimeHours: "4"}),
+			wantFinding:  true,
+			wantOverride: true,
+			wantReason:   "target: 24 hours",
+		},
+		{
+			name:         "malformed max runtime tag is ignored",
+			instance:     instance(25*time.Hour, map[string]string{TagMaxRuntimeHours: "forever"}),
+			wantFinding:  true,
+			wantOverride: true,
+			wantReason:   "ignored",
+		},
+		{
+			name:         "NaN max runtime tag is ignored",
+			instance:     instance(25*time.Hour, map[string]string{TagMaxRuntimeHours: "NaN"}),
+			wantFinding:  true,
+			wantOverride: true,
+			wantReason:   "not a positive number",
+		},
+		{
+			name:         "infinite max runtime tag is ignored",
+			instance:     instance(25*time.Hour, map[string]string{TagMaxRuntimeHours: "+Inf"}),
+			wantFinding:  true,
+			wantOverride: true,
+			wantReason:   "not a positive number",
+		},
+		{
+			name:         "future expiry extends instance",
+			instance:     instance(25*time.Hour, map[string]string{TagExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)}),
+			wantOverride: true,
+			wantReason:   "expires at",
+		},
+		{
+			name:         "past expiry makes instance due",
+			instance:     instance(2*time.Hour, map[string]string{TagExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339)}),
+			wantFinding:  true,
+			wantOverride: true,
+			wantReason:   "expires at",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chk := &Checker{Config: &config.Config{Targets: []config.Target{target}}}
+			finding := chk.checkInstanceRuntime(tt.instance, []config.Target{target})
+			if finding == nil {
+				t.Fatal("Expected the instance to match the target")
+			}
+
+			if (finding.Decision == DecisionAct) != tt.wantFinding {
+				t.Errorf("Expected due=%v, got decision %s", tt.wantFinding, finding.Decision)
+			}
+			if (finding.Override != "") != tt.wantOverride {
+				t.Fatalf("Expected override=%v, got %q", tt.wantOverride, finding.Override)
+			}
+			if (finding.Decision == DecisionSkip) != tt.wantSkipped {
+				t.Errorf("Expected skipped=%v, got decision %s", tt.wantSkipped, finding.Decision)
+			}
+			if !strings.Contains(finding.Override, tt.wantReason) {
+				t.Errorf("Expected reason to contain %q, got %q", tt.wantReason, finding.Override)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package checker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Outcome is the result of warning about or acting on an instance
+type Outcome string
+
+const (
+	// OutcomeNone means nothing had to be done for the instance
+	OutcomeNone Outcome = "none"
+	// OutcomeSkipped means the instance was exempt by its tags
+	OutcomeSkipped Outcome = "skipped"
+	// OutcomeDryRun means the warning or action was only logged
+	OutcomeDryRun Outcome = "dry-run"
+	// OutcomeSucceeded means the warning or action was applied
+	OutcomeSucceeded Outcome = "succeeded"
+	// OutcomeFailed means the warning or action returned an error
+	OutcomeFailed Outcome = "failed"
+)
+
+// RunReport describes everything a single RunCheck saw and did.
+// Notifications, metrics and exit codes are all derived from it.
+type RunReport struct {
+	StartedAt  time.Time        `json:"startedAt"`
+	FinishedAt time.Time        `json:"finishedAt"`
+	DryRun     bool             `json:"dryRun"`
+	Digest     bool             `json:"digest,omitempty"` // Notified every outstanding instance, not only changes
+	Scopes     []ScopeReport    `json:"scopes"`
+	Instances  []InstanceReport `json:"instances"`
+}
+
+// ScopeReport describes the scan of one account and region
+type ScopeReport struct {
+	AccountID string        `json:"accountId,omitempty"`
+	Region    string        `json:"region"`
+	Queries   int           `json:"queries"`
+	Scanned   int           `json:"scanned"`
+	Matched   int           `json:"matched"`
+	Duration  time.Duration `json:"duration"`
+	Errors    []string      `json:"errors,omitempty"`
+
+	// DescribeDurations holds the latency of each DescribeInstances query, failed ones included
+	DescribeDurations []time.Duration `json:"describeDurations,omitempty"`
+}
+
+// InstanceReport describes one instance that matched a target and what was done about it
+type InstanceReport struct {
+	InstanceID     string              `json:"instanceId"`
+	InstanceType   string              `json:"instanceType"`
+	Name           string              `json:"name,omitempty"`
+	Tags           map[string]string   `json:"tags,omitempty"`
+	AccountID      string              `json:"accountId,omitempty"`
+	Region         string              `json:"region"`
+	TargetIndex    int                 `json:"targetIndex"`
+	Selection      string              `json:"selection,omitempty"`
+	RuntimeHours   float64             `json:"runtimeHours"`
+	RuntimeBasis   config.RuntimeBasis `json:"runtimeBasis,omitempty"`
+	ThresholdHours float64             `json:"thresholdHours"`
+	Action         config.Action       `json:"action"`
+	Decision       Decision            `json:"decision"`
+	DueAt          time.Time           `json:"dueAt,omitzero"`
+	Override       string              `json:"override,omitempty"`
+	Window         string              `json:"window,omitempty"`
+	Outcome        Outcome             `json:"outcome"`
+	Error          string              `json:"error,omitempty"`
+	Duration       time.Duration       `json:"duration,omitempty"`
+}
+
+// newInstanceReport returns the report entry for a finding before anything is done about it
+func newInstanceReport(finding Finding) InstanceReport {
+	name, _ := getTag(finding.Instance, "Name")
+	outcome := OutcomeNone
+	if finding.Decision == DecisionSkip {
+		outcome = OutcomeSkipped
+	}
+	tags := make(map[string]string, len(finding.Instance.Tags))
+	for _, tag := range finding.Instance.Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return InstanceReport{
+		InstanceID:     aws.ToString(finding.Instance.InstanceId),
+		InstanceType:   string(finding.Instance.InstanceType),
+		Name:           name,
+		Tags:           tags,
+		AccountID:      finding.Scope.AccountID,
+		Region:         finding.Scope.Region,
+		TargetIndex:    finding.TargetIndex,
+		Selection:      finding.Selection,
+		RuntimeHours:   finding.Runtime.Hours(),
+		RuntimeBasis:   finding.RuntimeBasis,
+		ThresholdHours: finding.Target.MaxRuntimeHours,
+		Action:         finding.Target.EffectiveAction(),
+		Decision:       finding.Decision,
+		DueAt:          finding.DueAt,
+		Override:       finding.Override,
+		Window:         finding.Window,
+		Outcome:        outcome,
+	}
+}
+
+// Duration returns how long the run took
+func (r *RunReport) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Scanned returns the number of distinct instances returned by EC2 across all scopes
+func (r *RunReport) Scanned() int {
+	total := 0
+	for _, scope := range r.Scopes {
+		total += scope.Scanned
+	}
+	return total
+}
+
+// Count returns the number of instances with the given decision
+func (r *RunReport) Count(decision Decision) int {
+	total := 0
+	for _, instance := range r.Instances {
+		if instance.Decision == decision {
+			total++
+		}
+	}
+	return total
+}
+
+// Outstanding returns the number of instances warned about, waiting for their action after
+// a warning or acted on
+func (r *RunReport) Outstanding() int {
+	return r.Count(DecisionWarn) + r.Count(DecisionPending) + r.Count(DecisionAct)
+}
+
+// Errors returns every scan and action error of the run
+func (r *RunReport) Errors() []string {
+	var errs []string
+	for _, scope := range r.Scopes {
+		for _, err := range scope.Errors {
+			errs = append(errs, fmt.Sprintf("%s: %s", location(scope.AccountID, scope.Region), err))
+		}
+	}
+	for _, instance := range r.Instances {
+		if instance.Outcome == OutcomeFailed {
+			errs = append(errs, fmt.Sprintf("%s: %s", instance.InstanceID, instance.Error))
+		}
+	}
+	return errs
+}
+
+// Failed reports whether any scan or action failed
+func (r *RunReport) Failed() bool {
+	return len(r.Errors()) > 0
+}
+
+// Message renders the report with the built-in plain-text body template
+func (r *RunReport) Message() string {
+	_, body, _ := renderMessage(newNotificationData(r, nil, config.FormatPlaintext), config.MessageTemplate{})
+	return body
+}
+
+// location describes where an instance lives, omitting the account for the default credentials
+func location(accountID, region string) string {
+	if accountID == "" {
+		return fmt.Sprintf("Region: %s", region)
+	}
+	return fmt.Sprintf("Account: %s, Region: %s", accountID, region)
+}
+
+// runtimeText describes an instance's runtime with the basis it was measured on
+func runtimeText(instance InstanceReport) string {
+	if instance.RuntimeBasis == "" {
+		return fmt.Sprintf("%.2f hours", instance.RuntimeHours)
+	}
+	return fmt.Sprintf("%.2f hours (%s)", instance.RuntimeHours, instance.RuntimeBasis)
+}
@@ -0,0 +1,159 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+func TestRunCheck_ReturnsReport(t *testing.T) {
+	instance := func(id string, runtime time.Duration, tags ...types.Tag) types.Instance {
+		return types.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: types.InstanceType("t2.micro"),
+			LaunchTime:   aws.Time(time.Now().Add(-runtime)),
+			Tags:         tags,
+		}
+	}
+
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{
+					Instances: []types.Instance{
+						instance("i-due", 25*time.Hour, types.Tag{Key: aws.String("Name"), Value: aws.String("build")}),
+						instance("i-fresh", time.Hour),
+						instance("i-exempt", 25*time.Hour, types.Tag{Key: aws.String(TagExempt), Value: aws.String("true")}),
+					},
+				}},
+			}, nil
+		},
+		TerminateInstancesFunc: func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+			return nil, errors.New("UnauthorizedOperation")
+		},
+	}
+
+	var published string
+	mockSNS := &MockSNSClient{
+		PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+			published = aws.ToString(params.Message)
+			return &sns.PublishOutput{}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		AWSRegion:   "us-east-1",
+		Targets:     []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
+		SNSTopicArn: "arn:aws:sns:us-east-1:123456789012:mytopic",
+	}
+	report := New(mockEC2, mockSNS, cfg).RunCheck(context.Background())
+
+	if report.Scanned() != 3 || len(report.Instances) != 3 {
+		t.Fatalf("Expected 3 scanned and matched instances, got %d and %d", report.Scanned(), len(report.Instances))
+	}
+	if report.FinishedAt.Before(report.StartedAt) {
+		t.Errorf("Expected FinishedAt after StartedAt, got %v and %v", report.StartedAt, report.FinishedAt)
+	}
+
+	want := map[string]struct {
+		decision Decision
+		outcome  Outcome
+	}{
+		"i-due":    {DecisionAct, OutcomeFailed},
+		"i-fresh":  {DecisionNone, OutcomeNone},
+		"i-exempt": {DecisionSkip, OutcomeSkipped},
+	}
+	for _, instance := range report.Instances {
+		w := want[instance.InstanceID]
+		if instance.Decision != w.decision || instance.Outcome != w.outcome {
+			t.Errorf("%s: expected %s/%s, got %s/%s", instance.InstanceID, w.decision, w.outcome, instance.Decision, instance.Outcome)
+		}
+	}
+
+	due := report.Instances[0]
+	if due.Name != "build" || due.Region != "us-east-1" || due.ThresholdHours != 24 || due.Action != config.ActionTerminate {
+		t.Errorf("Unexpected report entry %+v", due)
+	}
+	if !report.Failed() || len(report.Errors()) != 1 || !strings.Contains(report.Errors()[0], "UnauthorizedOperation") {
+		t.Errorf("Expected the failed termination in Errors, got %v", report.Errors())
+	}
+	if published != report.Message() {
+		t.Errorf("Expected the notification to be the report message, got %q", published)
+	}
+}
+
+func TestRunCheck_NothingDueSkipsNotification(t *testing.T) {
+	mockEC2 := &MockEC2Client{
+		DescribeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{{
+					Instances: []types.Instance{{
+						InstanceId:   aws.String("i-fresh"),
+						InstanceType: types.InstanceType("t2.micro"),
+						LaunchTime:   aws.Time(time.Now().Add(-time.Hour)),
+					}},
+				}},
+			}, nil
+		},
+	}
+	mockSNS := &MockSNSClient{
+		PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+			t.Error("Publish should not be called when nothing is due")
+			return &sns.PublishOutput{}, nil
+		},
+	}
+
+	cfg := &config.Config{
+		Targets:     []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}},
+		SNSTopicArn: "arn:aws:sns:us-east-1:123456789012:mytopic",
+	}
+	report := New(mockEC2, mockSNS, cfg).RunCheck(context.Background())
+
+	if report.Failed() {
+		t.Errorf("Expected no errors, got %v", report.Errors())
+	}
+	if report.Count(DecisionNone) != 1 {
+		t.Errorf("Expected 1 instance within its limit, got %d", report.Count(DecisionNone))
+	}
+}
+
+func TestRunReport_Message(t *testing.T) {
+	report := &RunReport{
+		Scopes: []ScopeReport{
+			{AccountID: "111111111111", Region: "us-east-1", Errors: []string{"AccessDenied"}},
+		},
+		Instances: []InstanceReport{
+			{InstanceID: "i-dry", Region: "us-east-1", InstanceType: "t2.micro", Action: config.ActionStop, Decision: DecisionAct, Outcome: OutcomeDryRun},
+			{InstanceID: "i-failed", Region: "us-east-1", InstanceType: "t2.micro", Action: config.ActionTerminate, Decision: DecisionAct, Outcome: OutcomeFailed, Error: "boom"},
+			{InstanceID: "i-exempt", Region: "us-east-1", Decision: DecisionSkip, Outcome: OutcomeSkipped, Override: "exempt by tag"},
+			{InstanceID: "i-extended", Region: "us-east-1", Decision: DecisionNone, Outcome: OutcomeNone, Override: "max runtime 48 hours"},
+		},
+	}
+
+	message := report.Message()
+	for _, want := range []string{
+		"Found 2 long-running instances",
+		"DRY RUN: Would apply stop to instance i-dry",
+		"Failed to terminate instance i-failed: boom",
+		"2 instances were skipped or overridden by tags",
+		"i-exempt, Region: us-east-1, Skipped: exempt by tag",
+		"i-extended, Region: us-east-1, Overridden: max runtime 48 hours",
+		"Account: 111111111111, Region: us-east-1: AccessDenied",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected message to contain %q, got %q", want, message)
+		}
+	}
+	if strings.Contains(message, "approaching their runtime limit") {
+		t.Errorf("Expected no warning section, got %q", message)
+	}
+}
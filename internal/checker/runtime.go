@@ -0,0 +1,109 @@
+package checker
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const (
+	// TagFirstLaunchedAt (RFC3339) can be set at provisioning to the instance's creation time.
+	// The firstLaunch basis prefers it over the attach time of the root volume.
+	TagFirstLaunchedAt = "runtime-checker/first-launched-at"
+
+	// TagUptimeHours is written by the cumulative basis with the hours the instance ran
+	// in earlier starts
+	TagUptimeHours = "runtime-checker/uptime-hours"
+
+	// TagUptimeSince is written by the cumulative basis with the launch time of the start
+	// being counted
+	TagUptimeSince = "runtime-checker/uptime-since"
+
+	// TagUptimeSeenAt is written by the cumulative basis with the last time a check saw the
+	// instance running, which closes the start's count once the instance is restarted
+	TagUptimeSeenAt = "runtime-checker/uptime-seen-at"
+)
+
+// runtimeStart returns the time the instance's runtime is measured from under the target's
+// basis, and the basis actually used, which falls back to lastStart when the first launch
+// cannot be determined. For the cumulative basis it also returns the bookkeeping tags to
+// write on the instance.
+func runtimeStart(instance types.Instance, target config.Target, now time.Time) (time.Time, config.RuntimeBasis, map[string]string) {
+	launchTime := *instance.LaunchTime
+
+	switch target.EffectiveRuntimeBasis() {
+	case config.BasisFirstLaunch:
+		if firstLaunch, ok := firstLaunchTime(instance); ok {
+			return firstLaunch, config.BasisFirstLaunch, nil
+		}
+		slog.Warn("First launch unknown, measuring runtime from the last start", "instance_id", aws.ToString(instance.InstanceId))
+	case config.BasisCumulative:
+		start, uptimeTags := cumulativeStart(instance, now)
+		return start, config.BasisCumulative, uptimeTags
+	}
+	return launchTime, config.BasisLastStart, nil
+}
+
+// firstLaunchTime returns when the instance was first launched, from its first-launched-at
+// tag or else from when its root EBS volume was attached, which survives stops and starts
+func firstLaunchTime(instance types.Instance) (time.Time, bool) {
+	if firstLaunch, ok := getTagTime(instance, TagFirstLaunchedAt); ok {
+		return firstLaunch, true
+	}
+	for _, mapping := range instance.BlockDeviceMappings {
+		if aws.ToString(mapping.DeviceName) == aws.ToString(instance.RootDeviceName) && mapping.Ebs != nil && mapping.Ebs.AttachTime != nil {
+			return *mapping.Ebs.AttachTime, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// cumulativeStart returns the start that makes the instance's runtime its total uptime,
+// adding up its earlier starts from the bookkeeping tags, and the tags to write back.
+// The time between the last check that saw an earlier start and the instance's stop is
+// not counted.
+func cumulativeStart(instance types.Instance, now time.Time) (time.Time, map[string]string) {
+	launchTime := *instance.LaunchTime
+
+	var earlier time.Duration
+	if value, ok := getTag(instance, TagUptimeHours); ok {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || hours < 0 {
+			slog.Warn("Ignoring malformed uptime tag", "instance_id", aws.ToString(instance.InstanceId), "value", value)
+		} else {
+			earlier = time.Duration(hours * float64(time.Hour))
+		}
+	}
+
+	uptimeTags := map[string]string{TagUptimeSeenAt: now.UTC().Format(time.RFC3339)}
+	since, counted := getTagTime(instance, TagUptimeSince)
+	if !counted || !since.Equal(launchTime) {
+		// Restarted since the last check, so the earlier start ran at least until then
+		if seenAt, ok := getTagTime(instance, TagUptimeSeenAt); counted && ok && seenAt.After(since) {
+			earlier += seenAt.Sub(since)
+		}
+		uptimeTags[TagUptimeSince] = launchTime.UTC().Format(time.RFC3339)
+		uptimeTags[TagUptimeHours] = strconv.FormatFloat(earlier.Hours(), 'f', 4, 64)
+	}
+
+	return launchTime.Add(-earlier), uptimeTags
+}
+
+// getTagTime returns the RFC3339 time recorded in an instance tag, if any
+func getTagTime(instance types.Instance, key string) (time.Time, bool) {
+	value, ok := getTag(instance, key)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		slog.Warn("Ignoring malformed time tag", "instance_id", aws.ToString(instance.InstanceId), "key", key, "value", value)
+		return time.Time{}, false
+	}
+	return t, true
+}
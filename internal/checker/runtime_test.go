@@ -0,0 +1,183 @@
+package checker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestCheckInstanceRuntime_RuntimeBasis(t *testing.T) {
+	launched := time.Now().UTC().Truncate(time.Second).Add(-5 * time.Hour)
+	rfc3339 := func(t time.Time) string { return t.Format(time.RFC3339) }
+	rootVolume := []types.InstanceBlockDeviceMapping{
+		{DeviceName: aws.String("/dev/sdb"), Ebs: &types.EbsInstanceBlockDevice{AttachTime: aws.Time(launched.Add(-100 * time.Hour))}},
+		{DeviceName: aws.String("/dev/xvda"), Ebs: &types.EbsInstanceBlockDevice{AttachTime: aws.Time(launched.Add(-45 * time.Hour))}},
+	}
+
+	tests := []struct {
+		name        string
+		basis       config.RuntimeBasis
+		tags        map[string]string
+		mappings    []types.InstanceBlockDeviceMapping
+		wantHours   float64
+		wantBasis   config.RuntimeBasis
+		wantUptime  map[string]string // Expected bookkeeping tags besides the seen-at time
+		wantNoWrite bool
+	}{
+		{
+			name:        "last start by default",
+			mappings:    rootVolume,
+			wantHours:   5,
+			wantBasis:   config.BasisLastStart,
+			wantNoWrite: true,
+		},
+		{
+			name:        "first launch from the root volume",
+			basis:       config.BasisFirstLaunch,
+			mappings:    rootVolume,
+			wantHours:   50,
+			wantBasis:   config.BasisFirstLaunch,
+			wantNoWrite: true,
+		},
+		{
+			name:        "first launch tag wins over the root volume",
+			basis:       config.BasisFirstLaunch,
+			tags:        map[string]string{TagFirstLaunchedAt: rfc3339(launched.Add(-75 * time.Hour))},
+			mappings:    rootVolume,
+			wantHours:   80,
+			wantBasis:   config.BasisFirstLaunch,
+			wantNoWrite: true,
+		},
+		{
+			name:        "unknown first launch falls back to the last start",
+			basis:       config.BasisFirstLaunch,
+			tags:        map[string]string{TagFirstLaunchedAt: "yesterday"},
+			wantHours:   5,
+			wantBasis:   config.BasisLastStart,
+			wantNoWrite: true,
+		},
+		{
+			name:       "cumulative without bookkeeping starts counting",
+			basis:      config.BasisCumulative,
+			wantHours:  5,
+			wantBasis:  config.BasisCumulative,
+			wantUptime: map[string]string{TagUptimeHours: "0.0000", TagUptimeSince: rfc3339(launched)},
+		},
+		{
+			name:  "cumulative in the same start",
+			basis: config.BasisCumulative,
+			tags: map[string]string{
+				TagUptimeHours:  "10.0000",
+				TagUptimeSince:  rfc3339(launched),
+				TagUptimeSeenAt: rfc3339(launched.Add(4 * time.Hour)),
+			},
+			wantHours:  15,
+			wantBasis:  config.BasisCumulative,
+			wantUptime: map[string]string{},
+		},
+		{
+			name:  "cumulative after a restart adds the earlier start",
+			basis: config.BasisCumulative,
+			tags: map[string]string{
+				TagUptimeHours:  "10.0000",
+				TagUptimeSince:  rfc3339(launched.Add(-20 * time.Hour)),
+				TagUptimeSeenAt: rfc3339(launched.Add(-12 * time.Hour)),
+			},
+			wantHours:  23,
+			wantBasis:  config.BasisCumulative,
+			wantUptime: map[string]string{TagUptimeHours: "18.0000", TagUptimeSince: rfc3339(launched)},
+		},
+		{
+			name:       "cumulative ignores malformed uptime",
+			basis:      config.BasisCumulative,
+			tags:       map[string]string{TagUptimeHours: "many", TagUptimeSince: rfc3339(launched)},
+			wantHours:  5,
+			wantBasis:  config.BasisCumulative,
+			wantUptime: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.Instance{
+				InstanceId:          aws.String("i-restarted"),
+				InstanceType:        types.InstanceType("t2.micro"),
+				LaunchTime:          aws.Time(launched),
+				RootDeviceName:      aws.String("/dev/xvda"),
+				BlockDeviceMappings: tt.mappings,
+			}
+			for key, value := range tt.tags {
+				instance.Tags = append(instance.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+			}
+			target := config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 24, RuntimeBasis: tt.basis}
+
+			chk := New(nil, nil, &config.Config{})
+			finding := chk.checkInstanceRuntime(instance, []config.Target{target})
+			if finding == nil {
+				t.Fatal("Expected a finding")
+			}
+
+			if hours := finding.Runtime.Hours(); hours < tt.wantHours-0.1 || hours > tt.wantHours+0.1 {
+				t.Errorf("Expected a runtime of %g hours, got %.2f", tt.wantHours, hours)
+			}
+			if finding.RuntimeBasis != tt.wantBasis {
+				t.Errorf("Expected basis %s, got %s", tt.wantBasis, finding.RuntimeBasis)
+			}
+
+			if tt.wantNoWrite {
+				if finding.UptimeTags != nil {
+					t.Errorf("Expected no bookkeeping tags, got %v", finding.UptimeTags)
+				}
+				return
+			}
+			if _, ok := finding.UptimeTags[TagUptimeSeenAt]; !ok || len(finding.UptimeTags) != len(tt.wantUptime)+1 {
+				t.Errorf("Expected %v and the seen-at time, got %v", tt.wantUptime, finding.UptimeTags)
+			}
+			for key, want := range tt.wantUptime {
+				if got := finding.UptimeTags[key]; got != want {
+					t.Errorf("Expected %s=%q, got %q", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessInstances_RecordsUptime(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		var tagged []types.Tag
+		mockEC2 := &MockEC2Client{
+			CreateTagsFunc: func(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+				tagged = append(tagged, params.Tags...)
+				return &ec2.CreateTagsOutput{}, nil
+			},
+		}
+
+		chk := New(mockEC2, nil, &config.Config{DryRun: dryRun})
+		results := chk.processInstances(context.Background(), []Finding{{
+			Instance:     types.Instance{InstanceId: aws.String("i-cumulative"), InstanceType: types.InstanceType("t2.micro")},
+			Target:       config.Target{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+			Runtime:      3 * time.Hour,
+			RuntimeBasis: config.BasisCumulative,
+			Scope:        Scope{Region: "us-east-1", EC2Client: mockEC2},
+			Decision:     DecisionNone,
+			UptimeTags:   map[string]string{TagUptimeSeenAt: "2026-01-01T03:00:00Z", TagUptimeHours: "0.0000"},
+		}})
+
+		if dryRun {
+			if len(tagged) != 0 {
+				t.Errorf("Expected no tags in dry run, got %v", tagged)
+			}
+		} else if len(tagged) != 2 || aws.ToString(tagged[0].Key) != TagUptimeHours || aws.ToString(tagged[1].Key) != TagUptimeSeenAt {
+			t.Errorf("Expected the uptime tags in a single call, got %v", tagged)
+		}
+		if len(results) != 1 || results[0].Outcome != OutcomeNone || results[0].RuntimeBasis != config.BasisCumulative {
+			t.Errorf("Expected a cumulative result with nothing done, got %+v", results)
+		}
+	}
+}
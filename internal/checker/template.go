@@ -0,0 +1,204 @@
+package checker
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+)
+
+// NotificationData is what notification subject and body templates are rendered with
+type NotificationData struct {
+	*RunReport
+
+	// Format is the format the message is rendered in
+	Format config.MessageFormat
+
+	// Owner is who a personal message is addressed to, as named by the owner tag, empty
+	// for messages to the notifier's destination
+	Owner string
+
+	// Instances holds every instance in the message, Acted, Warned, Pending and Overridden
+	// those acted on, warned about, waiting for their action after a warning, which only
+	// digests include, and skipped or overridden by their tags
+	Instances  []NotificationInstance
+	Acted      []NotificationInstance
+	Warned     []NotificationInstance
+	Pending    []NotificationInstance
+	Overridden []NotificationInstance
+
+	// ScanErrors describes every failed scan, e.g. "Region: us-east-1: UnauthorizedOperation"
+	ScanErrors []string
+}
+
+// NotificationInstance is an instance in a notification, with the target it matched
+type NotificationInstance struct {
+	InstanceReport
+
+	// Target is the target the instance matched
+	Target config.Target
+
+	// Location is where the instance lives, e.g. "Account: 123456789012, Region: us-east-1"
+	Location string
+
+	// Runtime is the runtime with its basis, e.g. "26.50 hours (lastStart)"
+	Runtime string
+}
+
+// newNotificationData returns the template data for the report. Targets are looked up by
+// the instances' target index, those out of range are left empty.
+func newNotificationData(report *RunReport, targets []config.Target, format config.MessageFormat) NotificationData {
+	data := NotificationData{RunReport: report, Format: format}
+	for _, instance := range report.Instances {
+		entry := NotificationInstance{
+			InstanceReport: instance,
+			Location:       location(instance.AccountID, instance.Region),
+			Runtime:        runtimeText(instance),
+		}
+		if instance.TargetIndex < len(targets) {
+			entry.Target = targets[instance.TargetIndex]
+		}
+
+		data.Instances = append(data.Instances, entry)
+		switch instance.Decision {
+		case DecisionAct:
+			data.Acted = append(data.Acted, entry)
+		case DecisionWarn:
+			data.Warned = append(data.Warned, entry)
+		case DecisionPending:
+			data.Pending = append(data.Pending, entry)
+		}
+		if instance.Override != "" {
+			data.Overridden = append(data.Overridden, entry)
+		}
+	}
+	for _, scope := range report.Scopes {
+		for _, err := range scope.Errors {
+			data.ScanErrors = append(data.ScanErrors, location(scope.AccountID, scope.Region)+": "+err)
+		}
+	}
+	return data
+}
+
+// defaultBodies are the built-in body templates of each format
+var defaultBodies = map[config.MessageFormat]string{
+	config.FormatPlaintext: `{{with .Acted}}Found {{len .}} long-running instances:
+{{range .}}- ID: {{.InstanceID}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}, Action: {{.Action}}{{with .Window}}, Outside: {{.}}{{end}}
+{{if eq .Outcome "dry-run"}}DRY RUN: Would apply {{.Action}} to instance {{.InstanceID}}
+{{else if eq .Outcome "failed"}}Failed to {{.Action}} instance {{.InstanceID}}: {{.Error}}
+{{else if eq .Outcome "succeeded"}}{{if eq .Action "notify"}}Notified about instance {{.InstanceID}}, no action taken{{else}}Successfully applied {{.Action}} to instance {{.InstanceID}}{{end}}
+{{end}}{{end}}{{end}}{{with .Warned}}Warning: {{len .}} instances are approaching their runtime limit:
+{{range .}}- ID: {{.InstanceID}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}, Action: {{.Action}} after {{rfc3339 .DueAt}}
+{{if eq .Outcome "failed"}}Failed to record warning on instance {{.InstanceID}}, it will be warned again: {{.Error}}
+{{end}}{{end}}{{end}}{{with .Pending}}{{len .}} warned instances are waiting for their action:
+{{range .}}- ID: {{.InstanceID}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}, Action: {{.Action}} after {{rfc3339 .DueAt}}
+{{end}}{{end}}{{with .Overridden}}{{len .}} instances were skipped or overridden by tags:
+{{range .}}- ID: {{.InstanceID}}, {{.Location}}, {{if eq .Decision "skip"}}Skipped{{else}}Overridden{{end}}: {{.Override}}
+{{end}}{{end}}{{with .ScanErrors}}{{len .}} scans failed, instances there were not checked:
+{{range .}}- {{.}}
+{{end}}{{end}}`,
+
+	config.FormatMarkdown: `{{if .DryRun}}_Dry run: no instance was modified._
+{{end}}{{with .Acted}}Found {{len .}} long-running instances:
+{{range .}}- ` + "`{{.InstanceID}}`" + `{{with .Name}} ({{.}}){{end}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}{{with .Window}}, Outside: {{.}}{{end}}: {{if eq .Outcome "dry-run"}}would {{.Action}}{{else if eq .Outcome "failed"}}failed to {{.Action}}: {{.Error}}{{else if eq .Action "notify"}}no action taken{{else}}applied {{.Action}}{{end}}
+{{end}}{{end}}{{with .Warned}}Warning: {{len .}} instances are approaching their runtime limit:
+{{range .}}- ` + "`{{.InstanceID}}`" + `{{with .Name}} ({{.}}){{end}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}: {{.Action}} after {{rfc3339 .DueAt}}{{if eq .Outcome "failed"}}, failed to record the warning: {{.Error}}{{end}}
+{{end}}{{end}}{{with .Pending}}{{len .}} warned instances are waiting for their action:
+{{range .}}- ` + "`{{.InstanceID}}`" + `{{with .Name}} ({{.}}){{end}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}: {{.Action}} after {{rfc3339 .DueAt}}
+{{end}}{{end}}{{with .Overridden}}{{len .}} instances were skipped or overridden by tags:
+{{range .}}- ` + "`{{.InstanceID}}`" + `, {{.Location}}, {{if eq .Decision "skip"}}skipped{{else}}overridden{{end}}: {{.Override}}
+{{end}}{{end}}{{with .ScanErrors}}{{len .}} scans failed, instances there were not checked:
+{{range .}}- {{.}}
+{{end}}{{end}}`,
+
+	config.FormatHTML: `{{if .DryRun}}<p><em>Dry run: no instance was modified.</em></p>
+{{end}}{{with .Acted}}<p>Found {{len .}} long-running instances:</p>
+<ul>
+{{range .}}<li><code>{{.InstanceID}}</code>{{with .Name}} ({{.}}){{end}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}{{with .Window}}, Outside: {{.}}{{end}}: {{if eq .Outcome "dry-run"}}would {{.Action}}{{else if eq .Outcome "failed"}}<strong>failed to {{.Action}}</strong>: {{.Error}}{{else if eq .Action "notify"}}no action taken{{else}}applied {{.Action}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{with .Warned}}<p>Warning: {{len .}} instances are approaching their runtime limit:</p>
+<ul>
+{{range .}}<li><code>{{.InstanceID}}</code>{{with .Name}} ({{.}}){{end}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}: {{.Action}} after {{rfc3339 .DueAt}}{{if eq .Outcome "failed"}}, <strong>failed to record the warning</strong>: {{.Error}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{with .Pending}}<p>{{len .}} warned instances are waiting for their action:</p>
+<ul>
+{{range .}}<li><code>{{.InstanceID}}</code>{{with .Name}} ({{.}}){{end}}, {{.Location}}, Type: {{.InstanceType}}, Runtime: {{.Runtime}}: {{.Action}} after {{rfc3339 .DueAt}}</li>
+{{end}}</ul>
+{{end}}{{with .Overridden}}<p>{{len .}} instances were skipped or overridden by tags:</p>
+<ul>
+{{range .}}<li><code>{{.InstanceID}}</code>, {{.Location}}, {{if eq .Decision "skip"}}skipped{{else}}overridden{{end}}: {{.Override}}</li>
+{{end}}</ul>
+{{end}}{{with .ScanErrors}}<p>{{len .}} scans failed, instances there were not checked:</p>
+<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}`,
+}
+
+// executor is a parsed text or HTML template
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// renderMessage renders the subject and body of a notification with the template, falling
+// back to the built-in ones of the format for the parts it leaves empty
+func renderMessage(data NotificationData, tmpl config.MessageTemplate) (subject, body string, err error) {
+	format := data.Format
+	if !format.Valid() {
+		format = config.FormatPlaintext
+	}
+	subject = NotificationSubject
+	if data.Digest {
+		subject = DigestSubject
+	}
+	tmpl = tmpl.Or(config.MessageTemplate{Subject: subject, Body: defaultBodies[format]})
+
+	subjectTemplate, err := template.New("subject").Funcs(config.TemplateFuncs).Parse(tmpl.Subject)
+	if err != nil {
+		return "", "", err
+	}
+	if subject, err = execute(subjectTemplate, data); err != nil {
+		return "", "", err
+	}
+	if body, err = renderBody(format, tmpl.Body, data); err != nil {
+		return "", "", err
+	}
+	// Subjects are single lines, SNS rejects any other
+	return strings.Join(strings.Fields(subject), " "), body, nil
+}
+
+// renderHTML renders the HTML body of an email with the template, the built-in one if empty
+func renderHTML(data NotificationData, text string) (string, error) {
+	data.Format = config.FormatHTML
+	if text == "" {
+		text = defaultBodies[config.FormatHTML]
+	}
+	return renderBody(config.FormatHTML, text, data)
+}
+
+// renderBody renders a body template in the format. HTML bodies are rendered with
+// html/template, escaping the values they include.
+func renderBody(format config.MessageFormat, text string, data NotificationData) (string, error) {
+	var tmpl executor
+	var err error
+	if format == config.FormatHTML {
+		tmpl, err = htmltemplate.New("body").Funcs(htmltemplate.FuncMap(config.TemplateFuncs)).Parse(text)
+	} else {
+		tmpl, err = template.New("body").Funcs(config.TemplateFuncs).Parse(text)
+	}
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+// execute renders the template with the data
+func execute(tmpl executor, data NotificationData) (string, error) {
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
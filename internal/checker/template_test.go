@@ -0,0 +1,168 @@
+package checker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/notifier"
+)
+
+func TestRenderMessage(t *testing.T) {
+	targets := []config.Target{{InstanceType: "t2.micro", MaxRuntimeHours: 24}}
+	report := &RunReport{
+		DryRun: true,
+		Scopes: []ScopeReport{{Region: "eu-west-1", Errors: []string{"UnauthorizedOperation"}}},
+		Instances: []InstanceReport{
+			{InstanceID: "i-1", Name: "<build>", Tags: map[string]string{"Owner": "alice"}, Region: "us-east-1", InstanceType: "t2.micro",
+				RuntimeHours: 30, ThresholdHours: 24, Action: config.ActionStop, Decision: DecisionAct, Outcome: OutcomeDryRun},
+			{InstanceID: "i-2", Region: "us-east-1", InstanceType: "t2.micro", RuntimeHours: 22, Action: config.ActionStop,
+				Decision: DecisionWarn, DueAt: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), Outcome: OutcomeSucceeded},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		format      config.MessageFormat
+		template    config.MessageTemplate
+		wantSubject string
+		wantBody    []string
+		wantErr     bool
+	}{
+		{
+			name:        "plaintext",
+			format:      config.FormatPlaintext,
+			wantSubject: NotificationSubject,
+			wantBody:    []string{report.Message()},
+		},
+		{
+			name:        "markdown",
+			format:      config.FormatMarkdown,
+			wantSubject: NotificationSubject,
+			wantBody: []string{
+				"_Dry run: no instance was modified._\n",
+				"- `i-1` (<build>), Region: us-east-1, Type: t2.micro, Runtime: 30.00 hours: would stop\n",
+				"- `i-2`, Region: us-east-1, Type: t2.micro, Runtime: 22.00 hours: stop after 2026-03-02T12:00:00Z\n",
+				"- Region: eu-west-1: UnauthorizedOperation\n",
+			},
+		},
+		{
+			name:        "html escapes values",
+			format:      config.FormatHTML,
+			wantSubject: NotificationSubject,
+			wantBody:    []string{"<li><code>i-1</code> (&lt;build&gt;), Region: us-east-1", "<li>Region: eu-west-1: UnauthorizedOperation</li>"},
+		},
+		{
+			name:   "custom template",
+			format: config.FormatPlaintext,
+			template: config.MessageTemplate{
+				Subject: "{{len .Acted}} of {{len .Instances}}\ninstances {{if .DryRun}}would be {{end}}stopped",
+				Body:    "{{range .Acted}}{{.InstanceID}} {{upper (index .Tags \"Owner\")}} {{.Target.InstanceType}} {{.RuntimeHours}}/{{.ThresholdHours}} {{.Action}} {{.Outcome}}{{end}}",
+			},
+			wantSubject: "1 of 2 instances would be stopped",
+			wantBody:    []string{"i-1 ALICE t2.micro 30/24 stop dry-run"},
+		},
+		{
+			name:     "failing template",
+			format:   config.FormatPlaintext,
+			template: config.MessageTemplate{Body: "{{.Missing}}"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, body, err := renderMessage(newNotificationData(report, targets, tt.format), tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("Expected subject %q, got %q", tt.wantSubject, subject)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected body to contain %q, got:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestSendNotifications_Templates(t *testing.T) {
+	cfg := &config.Config{
+		Notifiers: config.Notifiers{{Name: "chat", Type: config.NotifierSlack, URL: "https://hooks.slack.com/x",
+			Template: config.MessageTemplate{Subject: "Runtime report"}}},
+		Targets: []config.Target{
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+			{InstanceType: "g5.xlarge", MaxRuntimeHours: 8, Template: config.MessageTemplate{Body: "GPU:{{range .Acted}} {{.InstanceID}}{{end}}"}},
+			{InstanceType: "m5.large", MaxRuntimeHours: 48, Template: config.MessageTemplate{Body: "{{.Missing}}"}},
+		},
+	}
+	report := &RunReport{Instances: []InstanceReport{
+		{InstanceID: "i-micro", TargetIndex: 0, Decision: DecisionAct, Outcome: OutcomeSucceeded, Action: config.ActionStop},
+		{InstanceID: "i-gpu", TargetIndex: 1, Decision: DecisionAct, Outcome: OutcomeSucceeded, Action: config.ActionStop},
+		{InstanceID: "i-large", TargetIndex: 2, Decision: DecisionAct, Outcome: OutcomeSucceeded, Action: config.ActionStop},
+		{InstanceID: "i-gpu-new", TargetIndex: 1, Decision: DecisionNone},
+	}}
+
+	chat := &MockNotifier{name: "chat"}
+	chk := New(nil, nil, cfg)
+	chk.Notifiers = []notifier.Notifier{chat}
+	chk.sendNotifications(context.Background(), report)
+
+	if len(chat.messages) != 3 {
+		t.Fatalf("Expected a message per template, got %d", len(chat.messages))
+	}
+	for _, message := range chat.messages[:2] {
+		if message.Subject != "Runtime report" || message.Format != config.FormatMarkdown {
+			t.Errorf("Expected the notifier's subject in markdown, got %q in %s", message.Subject, message.Format)
+		}
+	}
+	if body := chat.messages[0].Body; !strings.Contains(body, "`i-micro`") || strings.Contains(body, "i-gpu") {
+		t.Errorf("Expected the built-in markdown body for the first target only, got:\n%s", body)
+	}
+	if body := chat.messages[1].Body; body != "GPU: i-gpu" {
+		t.Errorf("Expected the target's body, got %q", body)
+	}
+	if message := chat.messages[2]; message.Subject != NotificationSubject || !strings.Contains(message.Body, "`i-large`") {
+		t.Errorf("Expected a failing template to fall back to the built-in template, got %q:\n%s", message.Subject, message.Body)
+	}
+}
+
+func TestSendNotifications_Email(t *testing.T) {
+	cfg := &config.Config{
+		Notifiers: config.Notifiers{{Name: "mail", Type: config.NotifierSMTP, Host: "localhost:25", From: "checker@example.com", To: []string{"ops@example.com"},
+			Template: config.MessageTemplate{HTML: "<b>{{len .Acted}} stopped</b>"}}},
+		Targets: []config.Target{
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+			{InstanceType: "g5.xlarge", MaxRuntimeHours: 8, Recipients: []string{"ml-team@example.com"}},
+			{InstanceType: "g5.2xlarge", MaxRuntimeHours: 8, Recipients: []string{"ml-team@example.com"}},
+		},
+	}
+	report := &RunReport{Instances: []InstanceReport{
+		{InstanceID: "i-micro", TargetIndex: 0, Decision: DecisionAct, Outcome: OutcomeSucceeded, Action: config.ActionStop},
+		{InstanceID: "i-gpu", TargetIndex: 1, Decision: DecisionAct, Outcome: OutcomeSucceeded, Action: config.ActionStop},
+		{InstanceID: "i-gpu-2", TargetIndex: 2, Decision: DecisionAct, Outcome: OutcomeSucceeded, Action: config.ActionStop},
+	}}
+
+	mail := &MockNotifier{name: "mail"}
+	chk := New(nil, nil, cfg)
+	chk.Notifiers = []notifier.Notifier{mail}
+	chk.sendNotifications(context.Background(), report)
+
+	if len(mail.messages) != 2 {
+		t.Fatalf("Expected a message per recipients, got %d", len(mail.messages))
+	}
+	if message := mail.messages[0]; message.To != nil || !strings.Contains(message.Body, "i-micro") || message.HTML != "<b>1 stopped</b>" {
+		t.Errorf("Expected the first target's instances to the notifier's recipients with HTML, got %+v", message)
+	}
+	message := mail.messages[1]
+	if strings.Join(message.To, ",") != "ml-team@example.com" || !strings.Contains(message.Body, "i-gpu-2") || message.HTML != "<b>2 stopped</b>" {
+		t.Errorf("Expected the GPU targets' instances to their recipients, got %+v", message)
+	}
+	if message.Format != config.FormatPlaintext {
+		t.Errorf("Expected a plaintext body alongside the HTML, got %s", message.Format)
+	}
+}
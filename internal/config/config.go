@@ -3,12 +3,54 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
 
+// Action is what the checker does with an instance that exceeds its target's runtime
+type Action string
+
+const (
+	ActionTerminate Action = "terminate"
+	ActionStop      Action = "stop"
+	ActionHibernate Action = "hibernate"
+	ActionTag       Action = "tag"
+	ActionNotify    Action = "notify"
+)
+
+// Valid reports whether the action is one the checker knows how to perform
+func (a Action) Valid() bool {
+	switch a {
+	case ActionTerminate, ActionStop, ActionHibernate, ActionTag, ActionNotify:
+		return true
+	}
+	return false
+}
+
+// RuntimeBasis is what an instance's runtime is measured from
+type RuntimeBasis string
+
+const (
+	// BasisLastStart measures the time since the instance was last started
+	BasisLastStart RuntimeBasis = "lastStart"
+	// BasisFirstLaunch measures the time since the instance was first launched, across stops
+	BasisFirstLaunch RuntimeBasis = "firstLaunch"
+	// BasisCumulative adds up the time the instance has been running, excluding stops
+	BasisCumulative RuntimeBasis = "cumulative"
+)
+
+// Valid reports whether the runtime basis is one the checker knows how to measure
+func (b RuntimeBasis) Valid() bool {
+	switch b {
+	case BasisLastStart, BasisFirstLaunch, BasisCumulative:
+		return true
+	}
+	return false
+}
+
 type Target struct {
 	// Filter by instance type (optional)
 	InstanceType string `json:"instanceType,omitempty"`
@@ -21,53 +63,333 @@ type Target struct {
 	// Example: {"Environment": "dev", "Team": "backend"}
 	Tags map[string]string `json:"tags,omitempty"`
 
-	// Maximum runtime in hours before termination
+	// Filter by tag expressions (all must match), for tag existence, negation, globs and regexes
+	// Example: [{"key": "Owner", "operator": "Exists"}, {"key": "Environment", "operator": "NotIn", "values": ["prod"]}]
+	MatchExpressions []Requirement `json:"matchExpressions,omitempty"`
+
+	// Maximum runtime in hours before the action is taken
+	// May be omitted with runWindows, to act on instances only outside the windows
 	MaxRuntimeHours float64 `json:"maxRuntimeHours"`
+
+	// Action to take once MaxRuntimeHours is exceeded (optional, defaults to terminate)
+	// One of: terminate, stop, hibernate, tag, notify
+	Action Action `json:"action,omitempty"`
+
+	// Warn once runtime reaches this percentage of MaxRuntimeHours (optional)
+	// When set, the action is deferred until the grace period after the warning has elapsed
+	// Example: 90
+	WarnAtPercent float64 `json:"warnAtPercent,omitempty"`
+
+	// Hours between the warning and the action (optional, defaults to the time between
+	// the warning threshold and MaxRuntimeHours)
+	GracePeriodHours float64 `json:"gracePeriodHours,omitempty"`
+
+	// Windows in which instances may run, whatever their runtime (optional)
+	// Outside all of them the action is taken right away
+	// Example: [{"days": ["Mon-Fri"], "start": "08:00", "end": "20:00", "timeZone": "Europe/Berlin"}]
+	RunWindows RunWindows `json:"runWindows,omitempty"`
+
+	// What the runtime is measured from (optional, defaults to lastStart)
+	// One of: lastStart, firstLaunch, cumulative
+	RuntimeBasis RuntimeBasis `json:"runtimeBasis,omitempty"`
+
+	// Notifiers to send notifications about the target's instances to (optional, defaults to all)
+	// Example: ["slack-dev"]
+	Notify []string `json:"notify,omitempty"`
+
+	// Email recipients of notifications about the target's instances, instead of the smtp
+	// notifiers' own (optional). The target's instances are then sent in an email of their own.
+	// Example: ["ml-team@example.com"]
+	Recipients []string `json:"recipients,omitempty"`
+
+	// Subject and body template for notifications about the target's instances, overriding
+	// the notifier's (optional). The target's instances are then sent in a message of their own.
+	Template MessageTemplate `json:"template,omitzero"`
+
+	// Regions this target applies to (optional, defaults to the global regions)
+	// Example: ["us-east-1", "eu-west-1"]
+	Regions []string `json:"regions,omitempty"`
+
+	// Precedence over other targets matching the same instance, higher wins (optional, defaults to 0)
+	// Between equal priorities, the target selection mode decides
+	Priority int `json:"priority,omitempty"`
 }
 
-type Config struct {
-	Targets               []Target `env:"-"` // Loaded from file, not env
-	AWSRegion             string   `env:"AWS_REGION,required"`
-	SNSTopicArn           string   `env:"SNS_TOPIC_ARN"`
-	Schedule              string   `env:"SCHEDULE"`
-	DryRun                bool     `env:"DRY_RUN" envDefault:"true"`
-	LeaderElectionEnabled bool     `env:"LEADER_ELECTION_ENABLED"`
-	PodName               string   `env:"POD_NAME"`
-	PodNamespace          string   `env:"POD_NAMESPACE"`
-	LeaseName             string   `env:"LEASE_NAME"`
-	VpcID                 string   `env:"VPC_ID"`
-	ConfigPath            string   `env:"CONFIG_PATH,required"` // Required env var for config file path
+// EffectiveAction returns the configured action, falling back to terminate
+func (t Target) EffectiveAction() Action {
+	if t.Action == "" {
+		return ActionTerminate
+	}
+	return t.Action
 }
 
-func Load() (*Config, error) {
-	// Parse environment variables into Config struct
-	cfg := &Config{}
-	if err := env.Parse(cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse environment variables: %w", err)
+// EffectiveRuntimeBasis returns the configured runtime basis, falling back to lastStart
+func (t Target) EffectiveRuntimeBasis() RuntimeBasis {
+	if t.RuntimeBasis == "" {
+		return BasisLastStart
 	}
+	return t.RuntimeBasis
+}
 
-	// Load Targets from config file
-	configFile, err := os.Open(cfg.ConfigPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %w", err)
+// DefaultSessionName is used for assumed-role sessions when an account does not set one
+const DefaultSessionName = "aws-ec2-runtime-checker"
+
+// Account is an AWS account scanned by assuming an IAM role in it
+type Account struct {
+	// IAM role to assume in the account
+	// Example: "arn:aws:iam::123456789012:role/ec2-runtime-checker"
+	RoleArn string `json:"roleArn"`
+
+	// External ID required by the role's trust policy (optional)
+	ExternalID string `json:"externalId,omitempty"`
+
+	// Session name for the assumed role (optional, defaults to aws-ec2-runtime-checker)
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+// AccountID returns the account ID embedded in the role ARN
+func (a Account) AccountID() string {
+	parts := strings.SplitN(a.RoleArn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+// EffectiveSessionName returns the configured session name, falling back to the default
+func (a Account) EffectiveSessionName() string {
+	if a.SessionName == "" {
+		return DefaultSessionName
 	}
-	defer configFile.Close()
+	return a.SessionName
+}
+
+// Accounts is a list of accounts, read from the ACCOUNTS environment variable as JSON
+type Accounts []Account
+
+// UnmarshalText parses a JSON array of accounts
+func (a *Accounts) UnmarshalText(text []byte) error {
+	return json.Unmarshal(text, (*[]Account)(a))
+}
+
+// MaxRuntime returns MaxRuntimeHours as a duration
+func (t Target) MaxRuntime() time.Duration {
+	return hours(t.MaxRuntimeHours)
+}
+
+// HasRuntimeLimit reports whether the target limits runtime, which targets with run windows need not
+func (t Target) HasRuntimeLimit() bool {
+	return t.MaxRuntimeHours != 0
+}
+
+// WarningThreshold returns the runtime at which a warning is sent, or zero if warnings are disabled
+func (t Target) WarningThreshold() time.Duration {
+	if t.WarnAtPercent <= 0 {
+		return 0
+	}
+	return hours(t.MaxRuntimeHours * t.WarnAtPercent / 100)
+}
+
+// GracePeriod returns how long the action is deferred after a warning
+func (t Target) GracePeriod() time.Duration {
+	if t.GracePeriodHours > 0 {
+		return hours(t.GracePeriodHours)
+	}
+	return t.MaxRuntime() - t.WarningThreshold()
+}
 
-	byteValue, err := io.ReadAll(configFile)
+// hours converts fractional hours to a duration
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+// Config is the resolved configuration. Every field can be set in the config file (with an
+// apiVersion), by environment variable or by flag, each overriding the previous.
+type Config struct {
+	Targets               []Target `json:"targets" env:"-"`                                      // Loaded from file, not env
+	AWSRegion             string   `json:"region" env:"AWS_REGION"`                              // Required
+	AWSRegions            []string `json:"regions,omitempty" env:"AWS_REGIONS" envSeparator:","` // Regions to scan, defaults to AWS_REGION
+	SNSTopicArn           string   `json:"snsTopicArn,omitempty" env:"SNS_TOPIC_ARN"`
+	Schedule              string   `json:"schedule,omitempty" env:"SCHEDULE"` // Cron schedule, required in cron mode
+	DryRun                bool     `json:"dryRun" env:"DRY_RUN"`              // Defaults to true
+	LeaderElectionEnabled bool     `json:"leaderElectionEnabled,omitempty" env:"LEADER_ELECTION_ENABLED"`
+	PodName               string   `json:"podName,omitempty" env:"POD_NAME"`
+	PodNamespace          string   `json:"podNamespace,omitempty" env:"POD_NAMESPACE"`
+	LeaseName             string   `json:"leaseName,omitempty" env:"LEASE_NAME"` // Defaults to ec2-checker-leader
+	VpcID                 string   `json:"vpcId,omitempty" env:"VPC_ID"`
+	Accounts              Accounts `json:"accounts,omitempty" env:"ACCOUNTS"`                           // JSON list of accounts to assume roles in
+	IncludeDefaultAccount bool     `json:"includeDefaultAccount" env:"INCLUDE_DEFAULT_ACCOUNT"`         // Also scan the account of the default credentials with Accounts, defaults to true
+	HTTPAddr              string   `json:"httpAddr,omitempty" env:"HTTP_ADDR"`                          // Address for the metrics and health server in cron mode, disabled when empty
+	HealthMissedRuns      int      `json:"healthMissedRuns,omitempty" env:"HEALTH_MISSED_RUNS"`         // Scheduled checks that may be missed before liveness fails, defaults to 3
+	ConfigReloadInterval  Duration `json:"configReloadInterval,omitempty" env:"CONFIG_RELOAD_INTERVAL"` // How often cron mode checks the config file for changes, defaults to 30s, 0 disables
+	ConfigPath            string   `json:"-" env:"CONFIG_PATH"`                                         // Required, from env or flag only
+
+	// Which target applies to an instance matching several, defaults to the first in config order
+	TargetSelection TargetSelection `json:"targetSelection,omitempty" env:"TARGET_SELECTION"`
+
+	// JSON list of notifiers, in addition to SNSTopicArn
+	Notifiers Notifiers `json:"notifiers,omitempty" env:"NOTIFIERS"`
+
+	// Instance tag naming the owner sent personal notifications, defaults to Owner
+	OwnerTag string `json:"ownerTag,omitempty" env:"OWNER_TAG"`
+
+	// Only notify about instances that are new or whose action, decision or outcome changed
+	// since they were last notified, remembered in memory unless NotificationStatePath is set
+	NotifyChangesOnly bool `json:"notifyChangesOnly" env:"NOTIFY_CHANGES_ONLY"`
+
+	// File remembering the notified instances across restarts, in memory only when empty
+	NotificationStatePath string `json:"notificationStatePath,omitempty" env:"NOTIFICATION_STATE_PATH"`
+
+	// Cron schedule of digests of every outstanding instance in cron mode, disabled when empty
+	DigestSchedule string `json:"digestSchedule,omitempty" env:"DIGEST_SCHEDULE"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in the config file and environment
+type Duration time.Duration
+
+// UnmarshalText parses a duration string such as "30s" or "5m"
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return err
 	}
+	*d = Duration(parsed)
+	return nil
+}
 
-	var targets []Target
-	if err := json.Unmarshal(byteValue, &targets); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+// MarshalText formats the duration as a string such as "30s"
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Defaults returns the configuration used where neither the file, environment nor flags set a value
+func Defaults() *Config {
+	return &Config{
+		DryRun:                true,
+		IncludeDefaultAccount: true,
+		LeaseName:             "ec2-checker-leader",
+		HealthMissedRuns:      3,
+		ConfigReloadInterval:  Duration(30 * time.Second),
+		OwnerTag:              "Owner",
+	}
+}
+
+// Load resolves the configuration from the defaults, the config file and environment variables
+func Load() (*Config, error) {
+	return LoadWithFlags(nil)
+}
+
+// LoadWithFlags resolves the configuration from the defaults, the config file, environment
+// variables and then flags, each overriding the previous. Flags are keyed by the name of
+// the environment variable they stand for, e.g. "AWS_REGION".
+func LoadWithFlags(flags map[string]string) (*Config, error) {
+	cfg := Defaults()
+
+	// The config file has to be located before anything else can be read from it
+	paths := &struct {
+		ConfigPath string `env:"CONFIG_PATH"`
+	}{}
+	if err := parseEnv(paths, flags); err != nil {
+		return nil, err
+	}
+	if paths.ConfigPath == "" {
+		return nil, fmt.Errorf("CONFIG_PATH is required")
+	}
+	if err := loadFile(paths.ConfigPath, cfg); err != nil {
+		return nil, err
 	}
-	cfg.Targets = targets
 
-	// Set default LeaseName if not provided
-	if cfg.LeaseName == "" {
-		cfg.LeaseName = "ec2-checker-leader"
+	if err := parseEnv(cfg, flags); err != nil {
+		return nil, err
 	}
 
+	validation := &ValidationError{}
+	if cfg.AWSRegion == "" {
+		validation.add("region", "is required, set it in the config file, by AWS_REGION or by flag")
+	}
+	validateAccounts(cfg.Accounts, validation)
+	validateSelection(cfg.TargetSelection, validation)
+	validateNotifiers(cfg.Notifiers, cfg.SNSTopicArn, validation)
+	validateRoutes(cfg.Targets, cfg.AllNotifiers(), validation)
+	if err := validation.err(); err != nil {
+		return nil, err
+	}
+
+	cfg.AWSRegions = normalizeRegions(cfg.AWSRegions)
 	return cfg, nil
 }
+
+// parseEnv overrides fields of v with the environment variables that are set, then with flags
+func parseEnv(v any, flags map[string]string) error {
+	if err := env.Parse(v); err != nil {
+		return fmt.Errorf("failed to parse environment variables: %w", err)
+	}
+	if len(flags) == 0 {
+		return nil
+	}
+	if err := env.ParseWithOptions(v, env.Options{Environment: flags}); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the config that is safe to log
+func (c *Config) Redacted() Config {
+	redacted := *c
+	redacted.Accounts = make(Accounts, len(c.Accounts))
+	for i, account := range c.Accounts {
+		if account.ExternalID != "" {
+			account.ExternalID = redactedValue
+		}
+		redacted.Accounts[i] = account
+	}
+	redacted.Notifiers = make(Notifiers, len(c.Notifiers))
+	for i, n := range c.Notifiers {
+		redacted.Notifiers[i] = n.redacted()
+	}
+	return redacted
+}
+
+// redactedValue replaces secrets in logged configs
+const redactedValue = "REDACTED"
+
+// GlobalRegions returns the regions scanned for targets without their own region list
+func (c *Config) GlobalRegions() []string {
+	if len(c.AWSRegions) > 0 {
+		return c.AWSRegions
+	}
+	return []string{c.AWSRegion}
+}
+
+// Regions returns every region at least one target applies to, in configuration order
+func (c *Config) Regions() []string {
+	regions := slices.Clone(c.GlobalRegions())
+	for _, t := range c.Targets {
+		for _, region := range t.Regions {
+			if !slices.Contains(regions, region) {
+				regions = append(regions, region)
+			}
+		}
+	}
+	return regions
+}
+
+// TargetInRegion reports whether a target applies to instances in the given region
+func (c *Config) TargetInRegion(t Target, region string) bool {
+	if len(t.Regions) > 0 {
+		return slices.Contains(t.Regions, region)
+	}
+	return slices.Contains(c.GlobalRegions(), region)
+}
+
+// normalizeRegions trims whitespace and drops empty or duplicate entries
+func normalizeRegions(regions []string) []string {
+	var result []string
+	for _, region := range regions {
+		region = strings.TrimSpace(region)
+		if region != "" && !slices.Contains(result, region) {
+			result = append(result, region)
+		}
+	}
+	return result
+}
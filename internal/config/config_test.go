@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -95,3 +100,415 @@ func TestDryRunDefaultValue(t *testing.T) {
 		t.Error("Expected DryRun to default to true, got false")
 	}
 }
+
+func TestRegions(t *testing.T) {
+	cfg := &Config{
+		AWSRegion: "us-east-1",
+		Targets: []Target{
+			{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+			{InstanceType: "t3.micro", MaxRuntimeHours: 24, Regions: []string{"eu-west-1", "us-east-1"}},
+		},
+	}
+
+	if got := cfg.Regions(); len(got) != 2 || got[0] != "us-east-1" || got[1] != "eu-west-1" {
+		t.Errorf("Expected [us-east-1 eu-west-1], got %v", got)
+	}
+	if cfg.TargetInRegion(cfg.Targets[0], "eu-west-1") {
+		t.Error("Expected target without regions not to apply outside the global regions")
+	}
+	if !cfg.TargetInRegion(cfg.Targets[1], "eu-west-1") {
+		t.Error("Expected target to apply to its own region")
+	}
+
+	cfg.AWSRegions = []string{"ap-south-1"}
+	if cfg.TargetInRegion(cfg.Targets[0], "us-east-1") {
+		t.Error("Expected AWS_REGIONS to replace AWS_REGION as the global region list")
+	}
+}
+
+func TestLoadRegionsFromEnv(t *testing.T) {
+	content := `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "regions": [" eu-west-1 "]}]`
+	tmpfile, err := os.CreateTemp("", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CONFIG_PATH", tmpfile.Name())
+	os.Setenv("AWS_REGION", "us-east-1")
+	os.Setenv("AWS_REGIONS", "us-east-1, us-west-2,")
+	defer func() {
+		os.Unsetenv("CONFIG_PATH")
+		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("AWS_REGIONS")
+	}()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []string{"us-east-1", "us-west-2", "eu-west-1"}
+	if got := cfg.Regions(); !slices.Equal(got, want) {
+		t.Errorf("Expected regions %v, got %v", want, got)
+	}
+}
+
+func TestLoadAccountsFromEnv(t *testing.T) {
+	content := `[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`
+	tmpfile, err := os.CreateTemp("", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CONFIG_PATH", tmpfile.Name())
+	os.Setenv("AWS_REGION", "us-east-1")
+	os.Setenv("ACCOUNTS", `[
+		{"roleArn": "arn:aws:iam::111111111111:role/checker", "externalId": "secret"},
+		{"roleArn": "arn:aws:iam::222222222222:role/checker", "sessionName": "sandbox-scan"}
+	]`)
+	defer func() {
+		os.Unsetenv("CONFIG_PATH")
+		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("ACCOUNTS")
+	}()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(cfg.Accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %d", len(cfg.Accounts))
+	}
+	if got := cfg.Accounts[0].AccountID(); got != "111111111111" {
+		t.Errorf("Expected account ID 111111111111, got %s", got)
+	}
+	if got := cfg.Accounts[0].EffectiveSessionName(); got != DefaultSessionName {
+		t.Errorf("Expected default session name, got %s", got)
+	}
+	if got := cfg.Accounts[1].EffectiveSessionName(); got != "sandbox-scan" {
+		t.Errorf("Expected session name sandbox-scan, got %s", got)
+	}
+
+	os.Setenv("ACCOUNTS", `[{"roleArn": "not-an-arn"}]`)
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid role ARN, got nil")
+	}
+}
+
+func TestLoadNotifiersFromEnv(t *testing.T) {
+	content := `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "notify": ["slack-dev"]}]`
+	tmpfile, err := os.CreateTemp("", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CONFIG_PATH", tmpfile.Name())
+	os.Setenv("AWS_REGION", "us-east-1")
+	os.Setenv("SNS_TOPIC_ARN", "arn:aws:sns:us-east-1:123456789012:ops")
+	os.Setenv("NOTIFIERS", `[{"name": "slack-dev", "type": "slack", "url": "https://hooks.slack.com/services/T000/B000/XXXX"}]`)
+	defer func() {
+		os.Unsetenv("CONFIG_PATH")
+		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("SNS_TOPIC_ARN")
+		os.Unsetenv("NOTIFIERS")
+	}()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	all := cfg.AllNotifiers()
+	if len(all) != 2 || all[0].Name != SNSNotifierName || all[1].Name != "slack-dev" {
+		t.Errorf("Expected the SNS topic and slack-dev notifiers, got %+v", all)
+	}
+	if !cfg.Targets[0].NotifiesTo("slack-dev") || cfg.Targets[0].NotifiesTo(SNSNotifierName) {
+		t.Errorf("Expected the target to notify slack-dev only")
+	}
+
+	os.Setenv("NOTIFIERS", `[{"name": "slack-ops", "type": "slack", "url": "https://hooks.slack.com/services/T000/B000/XXXX"}]`)
+	_, err = Load()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Field != "targets[0].notify" {
+		t.Errorf("Expected an unknown notifier error, got %v", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		content     string
+		wantErr     bool
+		wantTargets []string
+		wantFile    Config
+	}{
+		{
+			name:        "bare JSON array",
+			fileName:    "config.json",
+			content:     `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "regions": [" us-east-1 ", ""]}]`,
+			wantTargets: []string{"t2.micro"},
+		},
+		{
+			name:        "JSON object with global settings",
+			fileName:    "config.json",
+			content:     `{"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}], "regions": ["us-east-1", "us-east-1"], "vpcId": "vpc-1", "snsTopicArn": "arn:aws:sns:us-east-1:123456789012:topic"}`,
+			wantTargets: []string{"t2.micro"},
+			wantFile: Config{
+				AWSRegions:  []string{"us-east-1"},
+				VpcID:       "vpc-1",
+				SNSTopicArn: "arn:aws:sns:us-east-1:123456789012:topic",
+			},
+		},
+		{
+			name:     "YAML list by extension",
+			fileName: "config.yaml",
+			content: `- instanceType: t2.micro
+  maxRuntimeHours: 24
+- name: "dev-*"
+  maxRuntimeHours: 8
+  tags:
+    Team: backend
+`,
+			wantTargets: []string{"t2.micro", ""},
+		},
+		{
+			name:     "YAML object by content",
+			fileName: "config",
+			content: `regions: [us-east-1, eu-west-1]
+accounts:
+  - roleArn: arn:aws:iam::123456789012:role/checker
+targets:
+  - instanceType: t3.micro
+    maxRuntimeHours: 48
+    action: stop
+`,
+			wantTargets: []string{"t3.micro"},
+			wantFile: Config{
+				AWSRegions: []string{"us-east-1", "eu-west-1"},
+				Accounts:   []Account{{RoleArn: "arn:aws:iam::123456789012:role/checker"}},
+			},
+		},
+		{name: "malformed JSON", fileName: "config.json", content: `[{"instanceType": `, wantErr: true},
+		{name: "malformed YAML", fileName: "config.yml", content: "targets:\n  - instanceType: [t2.micro\n", wantErr: true},
+		{name: "invalid action", fileName: "config.json", content: `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "action": "explode"}]`, wantErr: true},
+		{name: "YAML unknown field", fileName: "config.yaml", content: "- instanceType: t2.micro\n  maxRuntimeHours: 24\n  maxRuntime: 5\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := LoadFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var types []string
+			for _, target := range file.Targets {
+				types = append(types, target.InstanceType)
+				for _, region := range target.Regions {
+					if region != strings.TrimSpace(region) || region == "" {
+						t.Errorf("Expected normalized target regions, got %q", target.Regions)
+					}
+				}
+			}
+			if !slices.Equal(types, tt.wantTargets) {
+				t.Errorf("Expected targets %v, got %v", tt.wantTargets, types)
+			}
+			if !slices.Equal(file.AWSRegions, tt.wantFile.AWSRegions) || file.VpcID != tt.wantFile.VpcID ||
+				file.SNSTopicArn != tt.wantFile.SNSTopicArn || !slices.Equal(file.Accounts, tt.wantFile.Accounts) {
+				t.Errorf("Expected settings %+v, got %+v", tt.wantFile, *file)
+			}
+		})
+	}
+}
+
+func TestLoadFileSettingsUnderEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `regions: [eu-west-1]
+vpcId: vpc-file
+snsTopicArn: arn:aws:sns:eu-west-1:123456789012:file
+targets:
+  - instanceType: t2.micro
+    maxRuntimeHours: 24
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_REGIONS", "")
+	t.Setenv("ACCOUNTS", "")
+	t.Setenv("VPC_ID", "vpc-env")
+	t.Setenv("SNS_TOPIC_ARN", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !slices.Equal(cfg.AWSRegions, []string{"eu-west-1"}) {
+		t.Errorf("Expected regions from the file, got %v", cfg.AWSRegions)
+	}
+	if cfg.VpcID != "vpc-env" {
+		t.Errorf("Expected VPC_ID to override the file, got %q", cfg.VpcID)
+	}
+	if cfg.SNSTopicArn != "arn:aws:sns:eu-west-1:123456789012:file" {
+		t.Errorf("Expected SNS topic from the file, got %q", cfg.SNSTopicArn)
+	}
+}
+
+func TestLoadWithFlags_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `apiVersion: ec2-runtime-checker/v1
+kind: Config
+region: eu-west-1
+snsTopicArn: arn:aws:sns:eu-west-1:123456789012:file
+schedule: "*/5 * * * *"
+dryRun: false
+leaseName: file-lease
+configReloadInterval: 1m
+targets:
+  - instanceType: t2.micro
+    maxRuntimeHours: 24
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"AWS_REGION", "AWS_REGIONS", "SNS_TOPIC_ARN", "SCHEDULE", "DRY_RUN", "LEASE_NAME", "VPC_ID", "ACCOUNTS", "CONFIG_RELOAD_INTERVAL", "NOTIFY_CHANGES_ONLY"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("SNS_TOPIC_ARN", "arn:aws:sns:eu-west-1:123456789012:env")
+	t.Setenv("SCHEDULE", "0 * * * *")
+
+	cfg, err := LoadWithFlags(map[string]string{"SCHEDULE": "0 0 * * *"})
+	if err != nil {
+		t.Fatalf("LoadWithFlags() failed: %v", err)
+	}
+
+	checks := []struct {
+		field string
+		got   any
+		want  any
+	}{
+		{"region from file", cfg.AWSRegion, "eu-west-1"},
+		{"dry run from file over default", cfg.DryRun, false},
+		{"lease name from file over default", cfg.LeaseName, "file-lease"},
+		{"reload interval from file", cfg.ConfigReloadInterval, Duration(time.Minute)},
+		{"health default", cfg.HealthMissedRuns, 3},
+		{"every run notified by default", cfg.NotifyChangesOnly, false},
+		{"SNS topic from env over file", cfg.SNSTopicArn, "arn:aws:sns:eu-west-1:123456789012:env"},
+		{"schedule from flag over env", cfg.Schedule, "0 0 * * *"},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s: expected %v, got %v", check.field, check.want, check.got)
+		}
+	}
+}
+
+func TestLoadWithFlags_RegionRequired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_REGION", "")
+	os.Unsetenv("AWS_REGION")
+
+	_, err := LoadWithFlags(map[string]string{"CONFIG_PATH": path})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Field != "region" {
+		t.Fatalf("Expected a region error, got %v", err)
+	}
+
+	cfg, err := LoadWithFlags(map[string]string{"CONFIG_PATH": path, "AWS_REGION": "us-east-1"})
+	if err != nil || cfg.AWSRegion != "us-east-1" || cfg.ConfigPath != path {
+		t.Errorf("Expected region and path from flags, got %+v, %v", cfg, err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Accounts: Accounts{
+			{RoleArn: "arn:aws:iam::111111111111:role/checker", ExternalID: "s3cret"},
+			{RoleArn: "arn:aws:iam::222222222222:role/checker"},
+		},
+		Notifiers: Notifiers{
+			{Name: "slack", Type: NotifierSlack, URL: "https://hooks.slack.com/services/T000/B000/s3cret", Token: "xoxb-s3cret"},
+			{Name: "audit", Type: NotifierWebhook, URL: "https://audit.example.com/hook", Headers: map[string]string{"Authorization": "Bearer s3cret"}},
+		},
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.Accounts[0].ExternalID != "REDACTED" || redacted.Accounts[1].ExternalID != "" {
+		t.Errorf("Expected only set external IDs to be redacted, got %+v", redacted.Accounts)
+	}
+	if cfg.Accounts[0].ExternalID != "s3cret" {
+		t.Errorf("Expected the original config to be left untouched, got %+v", cfg.Accounts)
+	}
+	if redacted.Notifiers[0].URL != "https://hooks.slack.com/REDACTED" || redacted.Notifiers[0].Token != "REDACTED" || redacted.Notifiers[1].Headers["Authorization"] != "REDACTED" {
+		t.Errorf("Expected webhook secrets to be redacted, got %+v", redacted.Notifiers)
+	}
+	if cfg.Notifiers[1].Headers["Authorization"] != "Bearer s3cret" {
+		t.Errorf("Expected the original headers to be left untouched, got %+v", cfg.Notifiers)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	settings := Settings()
+
+	want := map[string]Setting{
+		"AWS_REGION":  {Env: "AWS_REGION", Flag: "aws-region"},
+		"DRY_RUN":     {Env: "DRY_RUN", Flag: "dry-run", Bool: true},
+		"CONFIG_PATH": {Env: "CONFIG_PATH", Flag: "config-path"},
+	}
+	for _, setting := range settings {
+		if setting.Env == "" || setting.Env == "-" {
+			t.Errorf("Expected only fields with an environment variable, got %+v", setting)
+		}
+		if w, ok := want[setting.Env]; ok && w != setting {
+			t.Errorf("Expected %+v, got %+v", w, setting)
+		}
+		delete(want, setting.Env)
+	}
+	if len(want) > 0 {
+		t.Errorf("Expected settings %v", want)
+	}
+	if settings[0].Env != "AWS_REGION" {
+		t.Errorf("Expected settings in field order, got %s first", settings[0].Env)
+	}
+}
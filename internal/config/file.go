@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion and Kind identify the versioned config document, which can hold every Config field
+const (
+	APIVersion = "ec2-runtime-checker/v1"
+	Kind       = "Config"
+)
+
+// legacyFields are the settings a config file without apiVersion may hold next to its targets
+var legacyFields = []string{"targets", "regions", "accounts", "vpcId", "snsTopicArn"}
+
+// LoadFile reads and validates the config file, returning the defaults overridden by its settings
+func LoadFile(path string) (*Config, error) {
+	cfg := Defaults()
+	if err := loadFile(path, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile reads and validates the config file and applies its settings to cfg
+func loadFile(path string, cfg *Config) error {
+	configFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer configFile.Close()
+
+	byteValue, err := io.ReadAll(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseFile(path, byteValue, cfg)
+}
+
+// parseFile strictly parses and validates the content of the config file at path and
+// applies its settings to cfg. The file is a bare list of targets, an object with targets
+// and the legacy global settings, or a versioned document with any Config field.
+func parseFile(path string, data []byte, cfg *Config) error {
+	if isYAML(path, data) {
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file: %w", err)
+		}
+		data = converted
+	}
+
+	if err := decodeFile(data, cfg); err != nil {
+		return err
+	}
+
+	validation := &ValidationError{}
+	validateTargets(cfg.Targets, validation)
+	validateAccounts(cfg.Accounts, validation)
+	validateSelection(cfg.TargetSelection, validation)
+	validateNotifiers(cfg.Notifiers, cfg.SNSTopicArn, validation)
+	if err := validation.err(); err != nil {
+		return err
+	}
+
+	cfg.AWSRegions = normalizeRegions(cfg.AWSRegions)
+	for i := range cfg.Targets {
+		cfg.Targets[i].Regions = normalizeRegions(cfg.Targets[i].Regions)
+	}
+	return nil
+}
+
+// isYAML reports whether the config file is YAML, going by its extension and otherwise by
+// its content, since JSON always starts with an array or object
+func isYAML(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) == 0 || (trimmed[0] != '[' && trimmed[0] != '{')
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TargetSelection decides which target applies to an instance that matches several
+type TargetSelection string
+
+const (
+	// SelectFirst applies the first matching target in config order
+	SelectFirst TargetSelection = "first"
+	// SelectMostSpecific applies the matching target with the most criteria, e.g. type
+	// and tags over type only, falling back to config order between equally specific targets
+	SelectMostSpecific TargetSelection = "mostSpecific"
+)
+
+// Valid reports whether the selection mode is known, the empty mode meaning SelectFirst
+func (s TargetSelection) Valid() bool {
+	switch s {
+	case "", SelectFirst, SelectMostSpecific:
+		return true
+	}
+	return false
+}
+
+// Specificity counts the criteria of the target: its instance type, Name pattern, each tag,
+// each match expression and its region list
+func (t Target) Specificity() int {
+	specificity := len(t.Tags) + len(t.MatchExpressions)
+	for _, set := range []bool{t.InstanceType != "", t.Name != "", len(t.Regions) > 0} {
+		if set {
+			specificity++
+		}
+	}
+	return specificity
+}
+
+// Precedes reports whether target a takes precedence over target b for an instance both
+// match, where a comes before b in the config, and explains why. A higher priority always
+// wins; otherwise the selection mode decides.
+func (s TargetSelection) Precedes(a, b Target) (bool, string) {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority, "higher priority"
+	}
+	if s == SelectMostSpecific && a.Specificity() != b.Specificity() {
+		return a.Specificity() > b.Specificity(), "more specific"
+	}
+	return true, "earlier in the config"
+}
+
+// validateSelection records an unknown target selection mode
+func validateSelection(selection TargetSelection, validation *ValidationError) {
+	if !selection.Valid() {
+		validation.add("targetSelection", "must be %q or %q, got %q", SelectFirst, SelectMostSpecific, selection)
+	}
+}
+
+// TargetWarnings returns a warning for every pair of targets that may select the same
+// instances with different limits, naming the target that wins for those instances.
+// Such overlaps are allowed but easy to get wrong.
+func (c *Config) TargetWarnings() []FieldError {
+	var warnings []FieldError
+	for j, b := range c.Targets {
+		for i, a := range c.Targets[:j] {
+			if !mayOverlap(a, b) || sameSelection(a, b) {
+				continue
+			}
+			if limitText(a) == limitText(b) {
+				continue
+			}
+
+			winner, reason := i, ""
+			if precedes, why := c.TargetSelection.Precedes(a, b); precedes {
+				reason = why
+			} else {
+				winner, reason = j, why
+			}
+			warnings = append(warnings, FieldError{
+				Field: fmt.Sprintf("targets[%d]", j),
+				Message: fmt.Sprintf("may select the same instances as targets[%d] with a different limit (%s vs %s), targets[%d] applies to them (%s)",
+					i, limitText(a), limitText(b), winner, reason),
+			})
+		}
+	}
+	return warnings
+}
+
+// limitText describes when the target acts, e.g. stop after 24h or outside daily 08:00-20:00 UTC
+func limitText(t Target) string {
+	var limits []string
+	if t.HasRuntimeLimit() {
+		limits = append(limits, fmt.Sprintf("after %gh", t.MaxRuntimeHours))
+	}
+	if len(t.RunWindows) > 0 {
+		limits = append(limits, "outside "+t.RunWindows.String())
+	}
+	return fmt.Sprintf("%s %s", t.EffectiveAction(), strings.Join(limits, " or "))
+}
+
+// mayOverlap reports whether some instance could match both targets. It only rules out
+// overlaps that are certain from the config: different instance types, different values
+// of the same tag, different literal names or disjoint region lists.
+func mayOverlap(a, b Target) bool {
+	if a.InstanceType != "" && b.InstanceType != "" && a.InstanceType != b.InstanceType {
+		return false
+	}
+	if isLiteralName(a.Name) && isLiteralName(b.Name) && a.Name != b.Name {
+		return false
+	}
+	for key, value := range a.Tags {
+		if other, ok := b.Tags[key]; ok && other != value {
+			return false
+		}
+	}
+	if len(a.Regions) > 0 && len(b.Regions) > 0 && !slices.ContainsFunc(a.Regions, func(region string) bool {
+		return slices.Contains(b.Regions, region)
+	}) {
+		return false
+	}
+	return true
+}
+
+// isLiteralName reports whether a Name pattern only matches itself
+func isLiteralName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `*?[\`)
+}
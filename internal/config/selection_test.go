@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrecedes(t *testing.T) {
+	typeOnly := Target{InstanceType: "t2.micro", MaxRuntimeHours: 24}
+	typeAndTags := Target{InstanceType: "t2.micro", Tags: map[string]string{"Team": "ci"}, MaxRuntimeHours: 8}
+
+	tests := []struct {
+		name       string
+		selection  TargetSelection
+		a, b       Target
+		want       bool
+		wantReason string
+	}{
+		{name: "first in order", selection: SelectFirst, a: typeOnly, b: typeAndTags, want: true, wantReason: "earlier in the config"},
+		{name: "default mode is first", a: typeOnly, b: typeAndTags, want: true, wantReason: "earlier in the config"},
+		{name: "most specific", selection: SelectMostSpecific, a: typeOnly, b: typeAndTags, want: false, wantReason: "more specific"},
+		{name: "equally specific", selection: SelectMostSpecific, a: typeAndTags, b: typeAndTags, want: true, wantReason: "earlier in the config"},
+		{name: "priority beats order", selection: SelectFirst, a: typeOnly, b: Target{InstanceType: "t2.micro", Priority: 1}, want: false, wantReason: "higher priority"},
+		{name: "priority beats specificity", selection: SelectMostSpecific, a: Target{InstanceType: "t2.micro", Priority: 5}, b: typeAndTags, want: true, wantReason: "higher priority"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.selection.Precedes(tt.a, tt.b)
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("Precedes() = %v, %q, want %v, %q", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestTargetWarnings(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection TargetSelection
+		targets   []Target
+		want      []string
+	}{
+		{
+			name: "overlap with different thresholds",
+			targets: []Target{
+				{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+				{InstanceType: "t2.micro", Tags: map[string]string{"Team": "ci"}, MaxRuntimeHours: 8},
+			},
+			want: []string{"targets[1]: may select the same instances as targets[0] with a different limit (terminate after 24h vs terminate after 8h), targets[0] applies to them (earlier in the config)"},
+		},
+		{
+			name:      "most specific wins the overlap",
+			selection: SelectMostSpecific,
+			targets: []Target{
+				{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+				{InstanceType: "t2.micro", Tags: map[string]string{"Team": "ci"}, MaxRuntimeHours: 8},
+			},
+			want: []string{"targets[1] applies to them (more specific)"},
+		},
+		{
+			name: "different action",
+			targets: []Target{
+				{Name: "build-*", MaxRuntimeHours: 24},
+				{Tags: map[string]string{"Team": "ci"}, MaxRuntimeHours: 24, Action: ActionStop},
+			},
+			want: []string{"(terminate after 24h vs stop after 24h)"},
+		},
+		{
+			name: "same limits",
+			targets: []Target{
+				{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+				{Tags: map[string]string{"Team": "ci"}, MaxRuntimeHours: 24},
+			},
+		},
+		{
+			name: "disjoint",
+			targets: []Target{
+				{InstanceType: "t2.micro", MaxRuntimeHours: 24},
+				{InstanceType: "m5.large", MaxRuntimeHours: 8},
+				{InstanceType: "c5.large", Name: "build", Tags: map[string]string{"Team": "ci"}, Regions: []string{"us-east-1"}, MaxRuntimeHours: 2},
+				{InstanceType: "c5.large", Name: "deploy", MaxRuntimeHours: 4},
+				{InstanceType: "c5.large", Tags: map[string]string{"Team": "ci"}, Regions: []string{"eu-west-1"}, MaxRuntimeHours: 1},
+			},
+			// Only the last two can select the same instances
+			want: []string{"targets[4]: may select the same instances as targets[3]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{TargetSelection: tt.selection, Targets: tt.targets}
+			warnings := cfg.TargetWarnings()
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("Expected %d warnings, got %v", len(tt.want), warnings)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(warnings[i].Error(), want) {
+					t.Errorf("Expected warning containing %q, got %q", want, warnings[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Operator is how a match expression compares an instance tag with its values
+type Operator string
+
+const (
+	// OpIn matches when the tag is present and equals one of the values
+	OpIn Operator = "In"
+	// OpNotIn matches when the tag is absent or equals none of the values
+	OpNotIn Operator = "NotIn"
+	// OpExists matches when the tag is present, whatever its value
+	OpExists Operator = "Exists"
+	// OpDoesNotExist matches when the tag is absent
+	OpDoesNotExist Operator = "DoesNotExist"
+	// OpGlob matches when the tag is present and matches one of the glob patterns
+	OpGlob Operator = "Glob"
+	// OpRegex matches when the tag is present and fully matches one of the regular expressions
+	OpRegex Operator = "Regex"
+)
+
+// Requirement is a match expression on an instance tag, in the style of Kubernetes label selectors
+type Requirement struct {
+	// Tag key, e.g. "Owner" or "Name"
+	Key string `json:"key"`
+
+	// One of: In, NotIn, Exists, DoesNotExist, Glob, Regex
+	Operator Operator `json:"operator"`
+
+	// Values for In and NotIn, patterns for Glob and Regex, empty for Exists and DoesNotExist
+	Values []string `json:"values,omitempty"`
+}
+
+// Matches reports whether a tag with the given value, or an absent tag, satisfies the requirement
+func (r Requirement) Matches(value string, present bool) bool {
+	switch r.Operator {
+	case OpIn:
+		return present && slices.Contains(r.Values, value)
+	case OpNotIn:
+		return !present || !slices.Contains(r.Values, value)
+	case OpExists:
+		return present
+	case OpDoesNotExist:
+		return !present
+	case OpGlob:
+		return present && slices.ContainsFunc(r.Values, func(pattern string) bool {
+			matched, err := filepath.Match(pattern, value)
+			return err == nil && matched
+		})
+	case OpRegex:
+		return present && slices.ContainsFunc(r.Values, func(pattern string) bool {
+			re, err := compileRegex(pattern)
+			return err == nil && re.MatchString(value)
+		})
+	}
+	return false
+}
+
+// String describes the requirement, e.g. Environment NotIn [prod staging]
+func (r Requirement) String() string {
+	if len(r.Values) == 0 {
+		return fmt.Sprintf("%s %s", r.Key, r.Operator)
+	}
+	return fmt.Sprintf("%s %s [%s]", r.Key, r.Operator, strings.Join(r.Values, " "))
+}
+
+// equal reports whether two requirements are identical
+func (r Requirement) equal(other Requirement) bool {
+	return r.Key == other.Key && r.Operator == other.Operator && slices.Equal(r.Values, other.Values)
+}
+
+// regexCache holds the compiled Regex patterns, so instances are not matched by recompiling them
+var regexCache sync.Map
+
+// compileRegex compiles a Regex pattern anchored to match the whole value
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// validateRequirements records every problem with the match expressions of a target
+func validateRequirements(requirements []Requirement, field string, validation *ValidationError) {
+	for i, r := range requirements {
+		field := fmt.Sprintf("%s.matchExpressions[%d]", field, i)
+
+		if strings.TrimSpace(r.Key) == "" {
+			validation.add(field+".key", "must not be empty")
+		}
+		switch r.Operator {
+		case OpIn, OpNotIn, OpGlob, OpRegex:
+			if len(r.Values) == 0 {
+				validation.add(field+".values", "must not be empty for %s", r.Operator)
+			}
+		case OpExists, OpDoesNotExist:
+			if len(r.Values) > 0 {
+				validation.add(field+".values", "must be empty for %s", r.Operator)
+			}
+		default:
+			validation.add(field+".operator", "invalid operator %q, expected In, NotIn, Exists, DoesNotExist, Glob or Regex", r.Operator)
+		}
+
+		// Patterns are compiled here so that a bad one fails validation instead of never matching
+		for j, value := range r.Values {
+			switch r.Operator {
+			case OpGlob:
+				if _, err := filepath.Match(value, ""); err != nil {
+					validation.add(fmt.Sprintf("%s.values[%d]", field, j), "invalid pattern %q: %v", value, err)
+				}
+			case OpRegex:
+				if _, err := compileRegex(value); err != nil {
+					validation.add(fmt.Sprintf("%s.values[%d]", field, j), "invalid regular expression %q: %v", value, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestRequirementMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		requirement Requirement
+		value       string
+		present     bool
+		want        bool
+	}{
+		{name: "in", requirement: Requirement{Key: "Env", Operator: OpIn, Values: []string{"dev", "qa"}}, value: "qa", present: true, want: true},
+		{name: "in other value", requirement: Requirement{Key: "Env", Operator: OpIn, Values: []string{"dev", "qa"}}, value: "prod", present: true, want: false},
+		{name: "in absent", requirement: Requirement{Key: "Env", Operator: OpIn, Values: []string{"dev"}}, want: false},
+		{name: "not in", requirement: Requirement{Key: "Env", Operator: OpNotIn, Values: []string{"prod", "staging"}}, value: "dev", present: true, want: true},
+		{name: "not in listed value", requirement: Requirement{Key: "Env", Operator: OpNotIn, Values: []string{"prod", "staging"}}, value: "staging", present: true, want: false},
+		{name: "not in absent", requirement: Requirement{Key: "Env", Operator: OpNotIn, Values: []string{"prod"}}, want: true},
+		{name: "exists", requirement: Requirement{Key: "Owner", Operator: OpExists}, value: "", present: true, want: true},
+		{name: "exists absent", requirement: Requirement{Key: "Owner", Operator: OpExists}, want: false},
+		{name: "does not exist", requirement: Requirement{Key: "Owner", Operator: OpDoesNotExist}, want: true},
+		{name: "does not exist present", requirement: Requirement{Key: "Owner", Operator: OpDoesNotExist}, value: "alice", present: true, want: false},
+		{name: "glob", requirement: Requirement{Key: "Team", Operator: OpGlob, Values: []string{"data-*", "ml-?"}}, value: "ml-1", present: true, want: true},
+		{name: "glob miss", requirement: Requirement{Key: "Team", Operator: OpGlob, Values: []string{"data-*"}}, value: "web", present: true, want: false},
+		{name: "regex matches whole value", requirement: Requirement{Key: "Name", Operator: OpRegex, Values: []string{`build-\d+`}}, value: "build-42", present: true, want: true},
+		{name: "regex is anchored", requirement: Requirement{Key: "Name", Operator: OpRegex, Values: []string{`build-\d+`}}, value: "old-build-42", present: true, want: false},
+		{name: "regex absent", requirement: Requirement{Key: "Name", Operator: OpRegex, Values: []string{`.*`}}, want: false},
+		{name: "unknown operator", requirement: Requirement{Key: "Name", Operator: "Like", Values: []string{"x"}}, value: "x", present: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.requirement.Matches(tt.value, tt.present); got != tt.want {
+				t.Errorf("%s Matches(%q, %v) = %v, want %v", tt.requirement, tt.value, tt.present, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Setting is a Config field that can be set by environment variable and by flag
+type Setting struct {
+	Env  string // Environment variable, e.g. AWS_REGION
+	Flag string // Flag name, e.g. aws-region
+	Bool bool   // Whether the flag may be given without a value
+}
+
+// Settings returns every Config field that can be set by environment variable, in field order
+func Settings() []Setting {
+	var settings []Setting
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		settings = append(settings, Setting{
+			Env:  name,
+			Flag: strings.ToLower(strings.ReplaceAll(name, "_", "-")),
+			Bool: field.Type.Kind() == reflect.Bool,
+		})
+	}
+	return settings
+}
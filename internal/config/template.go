@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MessageFormat is the markup of notification messages
+type MessageFormat string
+
+const (
+	FormatPlaintext MessageFormat = "plaintext"
+	FormatMarkdown  MessageFormat = "markdown"
+	FormatHTML      MessageFormat = "html"
+)
+
+// Valid reports whether the format is one the checker has built-in templates for
+func (f MessageFormat) Valid() bool {
+	switch f {
+	case FormatPlaintext, FormatMarkdown, FormatHTML:
+		return true
+	}
+	return false
+}
+
+// MessageTemplate is a Go text/template for the subject and body of notifications. Fields
+// left empty fall back to the next template: a target's to its notifier's, a notifier's to
+// the built-in one for its format.
+type MessageTemplate struct {
+	// Example: "{{len .Acted}} instances stopped"
+	Subject string `json:"subject,omitempty"`
+
+	// Example: "{{range .Acted}}{{.InstanceID}} ({{.Name}}): {{.Outcome}}\n{{end}}"
+	Body string `json:"body,omitempty"`
+
+	// HTML body of email, sent alongside the plaintext body by smtp notifiers
+	// Example: "<ul>{{range .Acted}}<li>{{.InstanceID}}</li>{{end}}</ul>"
+	HTML string `json:"html,omitempty"`
+}
+
+// Or returns the template with its empty fields taken from the fallback
+func (m MessageTemplate) Or(fallback MessageTemplate) MessageTemplate {
+	if m.Subject == "" {
+		m.Subject = fallback.Subject
+	}
+	if m.Body == "" {
+		m.Body = fallback.Body
+	}
+	if m.HTML == "" {
+		m.HTML = fallback.HTML
+	}
+	return m
+}
+
+// TemplateFuncs are the functions available to message templates in addition to the
+// text/template builtins
+var TemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"rfc3339": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+}
+
+// validateTemplate records every part of a message template that does not parse
+func validateTemplate(m MessageTemplate, field string, validation *ValidationError) {
+	parts := []struct{ name, text string }{{"subject", m.Subject}, {"body", m.Body}, {"html", m.HTML}}
+	for _, part := range parts {
+		if _, err := template.New(part.name).Funcs(TemplateFuncs).Parse(part.text); err != nil {
+			validation.add(field+".template."+part.name, "invalid template: %v", err)
+		}
+	}
+}
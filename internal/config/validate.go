@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FieldError is a problem with a single field of the config, e.g. targets[2].maxRuntimeHours
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError lists every problem found in a config
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid config: %s", strings.Join(messages, "; "))
+}
+
+// add records a problem with the field
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the validation error, or nil when no problem was recorded
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// decodeFile strictly decodes the JSON content of the config file onto cfg
+func decodeFile(data []byte, cfg *Config) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		targets, err := decodeTargets(trimmed)
+		if err != nil {
+			return err
+		}
+		cfg.Targets = targets
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	validation := &ValidationError{}
+	var apiVersion, kind string
+	json.Unmarshal(fields["apiVersion"], &apiVersion)
+	json.Unmarshal(fields["kind"], &kind)
+	switch {
+	case fields["apiVersion"] == nil:
+		if fields["kind"] != nil {
+			validation.add("apiVersion", "is required with kind")
+		}
+		for name := range fields {
+			if !slices.Contains(legacyFields, name) && name != "kind" {
+				validation.add(name, "requires apiVersion %q", APIVersion)
+			}
+		}
+	case apiVersion != APIVersion:
+		validation.add("apiVersion", "unsupported version %q, expected %q", apiVersion, APIVersion)
+	case kind != Kind:
+		validation.add("kind", "must be %q, got %q", Kind, kind)
+	}
+	if err := validation.err(); err != nil {
+		return err
+	}
+
+	// Targets, accounts and notifiers are decoded separately so their errors carry the element's index
+	raw := struct {
+		*fileConfig
+		APIVersion string          `json:"apiVersion"`
+		Kind       string          `json:"kind"`
+		Targets    json.RawMessage `json:"targets"`
+		Accounts   json.RawMessage `json:"accounts"`
+		Notifiers  json.RawMessage `json:"notifiers"`
+	}{fileConfig: (*fileConfig)(cfg)}
+	decodeStrict(trimmed, &raw, "", validation)
+	if err := validation.err(); err != nil {
+		return err
+	}
+
+	if raw.Targets != nil {
+		targets, err := decodeTargets(raw.Targets)
+		if err != nil {
+			return err
+		}
+		cfg.Targets = targets
+	}
+	if raw.Accounts != nil {
+		accounts, err := decodeAccounts(raw.Accounts)
+		if err != nil {
+			return err
+		}
+		cfg.Accounts = accounts
+	}
+	if raw.Notifiers != nil {
+		notifiers, err := decodeNotifiers(raw.Notifiers)
+		if err != nil {
+			return err
+		}
+		cfg.Notifiers = notifiers
+	}
+	return nil
+}
+
+// fileConfig has the fields of Config, so the file can be decoded onto a Config while
+// targets, accounts and notifiers are decoded separately
+type fileConfig Config
+
+// decodeTargets strictly decodes a JSON array of targets. Each element is decoded on its
+// own so unknown fields and type mismatches can be reported with the target's index.
+func decodeTargets(data []byte) ([]Target, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	validation := &ValidationError{}
+	targets := make([]Target, len(elements))
+	for i, element := range elements {
+		decodeStrict(element, &targets[i], fmt.Sprintf("targets[%d]", i), validation)
+	}
+	if err := validation.err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// decodeAccounts strictly decodes a JSON array of accounts
+func decodeAccounts(data []byte) (Accounts, error) {
+	var elements []json.RawMessage
+	validation := &ValidationError{}
+	decodeStrict(data, &elements, "accounts", validation)
+	if err := validation.err(); err != nil {
+		return nil, err
+	}
+
+	accounts := make(Accounts, len(elements))
+	for i, element := range elements {
+		decodeStrict(element, &accounts[i], fmt.Sprintf("accounts[%d]", i), validation)
+	}
+	if err := validation.err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// decodeNotifiers strictly decodes a JSON array of notifiers
+func decodeNotifiers(data []byte) (Notifiers, error) {
+	var elements []json.RawMessage
+	validation := &ValidationError{}
+	decodeStrict(data, &elements, "notifiers", validation)
+	if err := validation.err(); err != nil {
+		return nil, err
+	}
+
+	notifiers := make(Notifiers, len(elements))
+	for i, element := range elements {
+		decodeStrict(element, &notifiers[i], fmt.Sprintf("notifiers[%d]", i), validation)
+	}
+	if err := validation.err(); err != nil {
+		return nil, err
+	}
+	return notifiers, nil
+}
+
+// decodeStrict decodes JSON into v, rejecting unknown fields, and records any error
+// located at the field below prefix
+func decodeStrict(data []byte, v any, prefix string, validation *ValidationError) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil {
+		return
+	}
+
+	field := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		validation.add(field(typeErr.Field), "must be %s, got %s", typeErr.Type, typeErr.Value)
+		return
+	}
+	// Unknown fields are only reported in the message: json: unknown field "foo"
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		validation.add(field(strings.Trim(name, `"`)), "unknown field")
+		return
+	}
+	if prefix == "" {
+		prefix = "config"
+	}
+	validation.add(prefix, "%s", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// validateTargets records every problem with the targets
+func validateTargets(targets []Target, validation *ValidationError) {
+	if len(targets) == 0 {
+		validation.add("targets", "must contain at least one target")
+	}
+
+	for i, t := range targets {
+		field := fmt.Sprintf("targets[%d]", i)
+
+		if t.InstanceType == "" && t.Name == "" && len(t.Tags) == 0 && len(t.MatchExpressions) == 0 {
+			validation.add(field, "must set at least one of instanceType, name, tags or matchExpressions, otherwise it matches every instance")
+		}
+		if t.Name != "" {
+			if _, err := filepath.Match(t.Name, ""); err != nil {
+				validation.add(field+".name", "invalid pattern %q: %v", t.Name, err)
+			}
+		}
+		for key := range t.Tags {
+			if strings.TrimSpace(key) == "" {
+				validation.add(field+".tags", "tag keys must not be empty")
+			}
+		}
+		validateRequirements(t.MatchExpressions, field, validation)
+		if t.MaxRuntimeHours < 0 || (t.MaxRuntimeHours == 0 && len(t.RunWindows) == 0) {
+			validation.add(field+".maxRuntimeHours", "must be greater than 0, got %g", t.MaxRuntimeHours)
+		}
+		validateWindows(t.RunWindows, field, validation)
+		if t.Action != "" && !t.Action.Valid() {
+			validation.add(field+".action", "invalid action %q", t.Action)
+		}
+		if t.WarnAtPercent < 0 || t.WarnAtPercent >= 100 {
+			validation.add(field+".warnAtPercent", "must be between 0 and 100, got %g", t.WarnAtPercent)
+		}
+		if t.GracePeriodHours < 0 {
+			validation.add(field+".gracePeriodHours", "must not be negative, got %g", t.GracePeriodHours)
+		}
+		if t.RuntimeBasis != "" && !t.RuntimeBasis.Valid() {
+			validation.add(field+".runtimeBasis", "invalid runtime basis %q, expected lastStart, firstLaunch or cumulative", t.RuntimeBasis)
+		}
+		validateRecipients(t.Recipients, field+".recipients", validation)
+		validateTemplate(t.Template, field, validation)
+
+		for j := range i {
+			if !sameSelection(targets[j], t) {
+				continue
+			}
+			// Only the priority can break the tie, otherwise the earlier target wins
+			if t.Priority > targets[j].Priority {
+				validation.add(fmt.Sprintf("targets[%d]", j), "selects the same instances as targets[%d], which has a higher priority, so it never applies", i)
+			} else {
+				validation.add(field, "selects the same instances as targets[%d], so it never applies", j)
+			}
+			break
+		}
+	}
+}
+
+// validateAccounts records every account whose role ARN is invalid
+func validateAccounts(accounts []Account, validation *ValidationError) {
+	for i, account := range accounts {
+		if !strings.HasPrefix(account.RoleArn, "arn:") || account.AccountID() == "" {
+			validation.add(fmt.Sprintf("accounts[%d].roleArn", i), "invalid role ARN %q", account.RoleArn)
+		}
+	}
+}
+
+// sameSelection reports whether two targets select exactly the same instances
+func sameSelection(a, b Target) bool {
+	return a.InstanceType == b.InstanceType &&
+		a.Name == b.Name &&
+		maps.Equal(a.Tags, b.Tags) &&
+		slices.EqualFunc(a.MatchExpressions, b.MatchExpressions, Requirement.equal) &&
+		slices.Equal(sortedRegions(a.Regions), sortedRegions(b.Regions))
+}
+
+func sortedRegions(regions []string) []string {
+	regions = normalizeRegions(regions)
+	slices.Sort(regions)
+	return regions
+}
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseTargets_Validation(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantFields []string
+	}{
+		{
+			name:    "valid",
+			content: `[{"instanceType": "t2.micro", "maxRuntimeHours": 24}, {"name": "dev-*", "maxRuntimeHours": 8}]`,
+		},
+		{
+			name:       "empty list",
+			content:    `[]`,
+			wantFields: []string{"targets"},
+		},
+		{
+			name:       "unknown field",
+			content:    `[{"instanceType": "t2.micro", "maxRuntimeHours": 24}, {"instanceType": "t3.micro", "maxRuntime": 24}]`,
+			wantFields: []string{"targets[1].maxRuntime"},
+		},
+		{
+			name:       "unknown top-level field",
+			content:    `{"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}], "region": "us-east-1"}`,
+			wantFields: []string{"region"},
+		},
+		{
+			name:    "versioned document",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "region": "us-east-1", "dryRun": false, "configReloadInterval": "1m", "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+		},
+		{
+			name:       "unsupported apiVersion",
+			content:    `{"apiVersion": "ec2-runtime-checker/v2", "kind": "Config", "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"apiVersion"},
+		},
+		{
+			name:       "wrong kind",
+			content:    `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Targets", "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"kind"},
+		},
+		{
+			name:       "unknown field in versioned document",
+			content:    `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "dryRunMode": false, "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"dryRunMode"},
+		},
+		{
+			name:       "malformed duration",
+			content:    `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "configReloadInterval": "soon", "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"config"},
+		},
+		{
+			name:       "unknown account field",
+			content:    `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "accounts": [{"roleArn": "arn:aws:iam::123456789012:role/checker", "role": "x"}], "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"accounts[0].role"},
+		},
+		{
+			name:       "object without targets",
+			content:    `{"regions": ["us-east-1"]}`,
+			wantFields: []string{"targets"},
+		},
+		{
+			name:       "invalid account in file",
+			content:    `{"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}], "accounts": [{"roleArn": "not-an-arn"}]}`,
+			wantFields: []string{"accounts[0].roleArn"},
+		},
+		{
+			name:       "wrong type",
+			content:    `[{"instanceType": "t2.micro", "maxRuntimeHours": "24"}]`,
+			wantFields: []string{"targets[0].maxRuntimeHours"},
+		},
+		{
+			name:       "zero and negative runtime",
+			content:    `[{"instanceType": "t2.micro"}, {"instanceType": "t3.micro", "maxRuntimeHours": -1}]`,
+			wantFields: []string{"targets[0].maxRuntimeHours", "targets[1].maxRuntimeHours"},
+		},
+		{
+			name:       "empty target matches everything",
+			content:    `[{"maxRuntimeHours": 24, "regions": ["us-east-1"]}]`,
+			wantFields: []string{"targets[0]"},
+		},
+		{
+			name:       "malformed name pattern",
+			content:    `[{"name": "dev-[", "maxRuntimeHours": 24}]`,
+			wantFields: []string{"targets[0].name"},
+		},
+		{
+			name:       "empty tag key",
+			content:    `[{"tags": {"": "x"}, "maxRuntimeHours": 24}]`,
+			wantFields: []string{"targets[0].tags"},
+		},
+		{
+			name:       "invalid action and warning settings",
+			content:    `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "action": "explode", "warnAtPercent": 100, "gracePeriodHours": -2}]`,
+			wantFields: []string{"targets[0].action", "targets[0].warnAtPercent", "targets[0].gracePeriodHours"},
+		},
+		{
+			name:       "runtime basis",
+			content:    `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "runtimeBasis": "cumulative"}, {"instanceType": "t3.micro", "maxRuntimeHours": 24, "runtimeBasis": "uptime"}]`,
+			wantFields: []string{"targets[1].runtimeBasis"},
+		},
+		{
+			name: "run windows without a runtime limit",
+			content: `[
+				{"tags": {"Env": "dev"}, "action": "stop", "runWindows": [{"days": ["Mon-Fri"], "start": "08:00", "end": "20:00", "timeZone": "Europe/Berlin"}]},
+				{"tags": {"Env": "test"}, "maxRuntimeHours": 12, "runWindows": [{"cron": "* 8-19 * * 1-5"}, {"start": "22:00", "end": "24:00"}]}
+			]`,
+		},
+		{
+			name: "invalid run windows",
+			content: `[
+				{"tags": {"Env": "dev"}, "runWindows": [
+					{"days": ["Mon-Fry"], "start": "8:00", "end": "24:00", "timeZone": "Mars/Olympus"},
+					{"start": "09:00", "end": "09:00"},
+					{"cron": "* 8-19 * *", "start": "08:00"},
+					{"days": ["Sat"]}
+				]},
+				{"tags": {"Env": "test"}}
+			]`,
+			wantFields: []string{
+				"targets[0].runWindows[0].timeZone", "targets[0].runWindows[0].days", "targets[0].runWindows[0].start",
+				"targets[0].runWindows[1].end",
+				"targets[0].runWindows[2]", "targets[0].runWindows[2].cron",
+				"targets[0].runWindows[3]",
+				"targets[1].maxRuntimeHours",
+			},
+		},
+		{
+			name: "notifiers",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "snsTopicArn": "arn:aws:sns:us-east-1:123456789012:ops",
+				"notifiers": [
+					{"name": "slack-dev", "type": "slack", "url": "https://hooks.slack.com/services/T000/B000/XXXX"},
+					{"name": "audit", "type": "webhook", "url": "https://audit.example.com/hook", "headers": {"Authorization": "Bearer x"}}
+				],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24, "notify": ["slack-dev", "sns"]}]}`,
+		},
+		{
+			name: "invalid notifiers",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "snsTopicArn": "arn:aws:sns:us-east-1:123456789012:ops",
+				"notifiers": [
+					{"name": "sns", "type": "sns", "topicArn": "arn:aws:sns:us-east-1:123456789012:other"},
+					{"name": "chat", "type": "slack", "url": "hooks.slack.com/services/T000", "headers": {"X": "y"}},
+					{"name": "chat", "type": "pager"}
+				],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"notifiers[0].name", "notifiers[1].url", "notifiers[1].headers", "notifiers[2].name", "notifiers[2].type"},
+		},
+		{
+			name: "templates",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config",
+				"notifiers": [{"name": "mail", "type": "webhook", "url": "https://example.com/hook", "format": "html", "template": {"subject": "{{len .Acted}} instances stopped"}}],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24, "template": {"body": "{{range .Acted}}{{.InstanceID}} {{index .Tags \"Owner\"}}\n{{end}}"}}]}`,
+		},
+		{
+			name: "invalid templates",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config",
+				"notifiers": [{"name": "chat", "type": "slack", "url": "https://hooks.slack.com/services/T000", "format": "rich", "template": {"body": "{{range .Acted}}"}}],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24, "template": {"subject": "{{owner .}}"}}]}`,
+			wantFields: []string{"targets[0].template.subject", "notifiers[0].format", "notifiers[0].template.body"},
+		},
+		{
+			name: "owner notifiers",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "ownerTag": "CreatedBy",
+				"notifiers": [
+					{"name": "mail", "type": "smtp", "host": "localhost:25", "from": "checker@example.com", "to": ["ops@example.com"], "notifyOwners": true},
+					{"name": "chat", "type": "slack", "url": "https://hooks.slack.com/services/T000", "token": "xoxb-token", "notifyOwners": true}
+				],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+		},
+		{
+			name: "invalid owner notifiers",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config",
+				"notifiers": [
+					{"name": "mail", "type": "smtp", "host": "localhost", "from": "checker", "url": "https://example.com"},
+					{"name": "chat", "type": "slack", "url": "https://hooks.slack.com/services/T000", "notifyOwners": true},
+					{"name": "cards", "type": "teams", "url": "https://example.com/hook", "token": "x", "notifyOwners": true}
+				],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"notifiers[0].host", "notifiers[0].from", "notifiers[0].to", "notifiers[0].url", "notifiers[1].token",
+				"notifiers[2].token", "notifiers[2].notifyOwners"},
+		},
+		{
+			name: "email notifiers",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config",
+				"notifiers": [
+					{"name": "mail", "type": "smtp", "host": "smtp.example.com:587", "from": "checker@example.com", "to": ["ops@example.com"],
+						"tls": "starttls", "username": "checker", "password": "s3cret", "template": {"html": "<p>{{len .Instances}}</p>"}}
+				],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24, "recipients": ["ml-team@example.com"]}]}`,
+		},
+		{
+			name: "invalid email notifiers",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config",
+				"notifiers": [
+					{"name": "mail", "type": "smtp", "host": "smtp.example.com:587", "from": "checker@example.com", "to": ["ops"],
+						"tls": "ssl", "username": "checker", "format": "html", "template": {"html": "{{.Instances"}},
+					{"name": "chat", "type": "slack", "url": "https://hooks.slack.com/services/T000", "password": "x", "template": {"html": "<p></p>"}}
+				],
+				"targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24, "recipients": ["", "ml-team"]}]}`,
+			wantFields: []string{"targets[0].recipients", "targets[0].recipients", "notifiers[0].template.html", "notifiers[0].to",
+				"notifiers[0].tls", "notifiers[0].password", "notifiers[0].format", "notifiers[1].password", "notifiers[1].template.html"},
+		},
+		{
+			name:       "unknown notifier field",
+			content:    `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "notifiers": [{"name": "chat", "type": "slack", "webhook": "https://hooks.slack.com"}], "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"notifiers[0].webhook"},
+		},
+		{
+			name: "duplicate target",
+			content: `[
+				{"instanceType": "t2.micro", "tags": {"Team": "a"}, "regions": ["us-east-1", "eu-west-1"], "maxRuntimeHours": 24},
+				{"instanceType": "t2.micro", "maxRuntimeHours": 24},
+				{"instanceType": "t2.micro", "tags": {"Team": "a"}, "regions": ["eu-west-1", "us-east-1"], "maxRuntimeHours": 48}
+			]`,
+			wantFields: []string{"targets[2]"},
+		},
+		{
+			// The later duplicate wins by priority, so the earlier one never applies
+			name: "duplicate target with higher priority",
+			content: `[
+				{"instanceType": "t2.micro", "maxRuntimeHours": 24},
+				{"instanceType": "t2.micro", "maxRuntimeHours": 8, "priority": 10}
+			]`,
+			wantFields: []string{"targets[0]"},
+		},
+		{
+			name: "duplicate target with lower priority",
+			content: `[
+				{"instanceType": "t2.micro", "maxRuntimeHours": 24, "priority": 10},
+				{"instanceType": "t2.micro", "maxRuntimeHours": 8}
+			]`,
+			wantFields: []string{"targets[1]"},
+		},
+		{
+			name:    "match expressions only",
+			content: `[{"matchExpressions": [{"key": "Owner", "operator": "Exists"}, {"key": "Environment", "operator": "NotIn", "values": ["prod", "staging"]}], "maxRuntimeHours": 24}]`,
+		},
+		{
+			name: "targets differing only in match expressions",
+			content: `[
+				{"instanceType": "t2.micro", "matchExpressions": [{"key": "Name", "operator": "Regex", "values": ["build-\\d+"]}], "maxRuntimeHours": 24},
+				{"instanceType": "t2.micro", "matchExpressions": [{"key": "Name", "operator": "Regex", "values": ["test-\\d+"]}], "maxRuntimeHours": 24}
+			]`,
+		},
+		{
+			name: "invalid match expressions",
+			content: `[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "matchExpressions": [
+				{"key": "", "operator": "Exists"},
+				{"key": "Team", "operator": "Like", "values": ["x"]},
+				{"key": "Team", "operator": "In"},
+				{"key": "Owner", "operator": "Exists", "values": ["alice"]},
+				{"key": "Name", "operator": "Glob", "values": ["dev-*", "dev-["]},
+				{"key": "Name", "operator": "Regex", "values": ["build-("]}
+			]}]`,
+			wantFields: []string{
+				"targets[0].matchExpressions[0].key",
+				"targets[0].matchExpressions[1].operator",
+				"targets[0].matchExpressions[2].values",
+				"targets[0].matchExpressions[3].values",
+				"targets[0].matchExpressions[4].values[1]",
+				"targets[0].matchExpressions[5].values[0]",
+			},
+		},
+		{
+			name:    "priority and target selection",
+			content: `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "targetSelection": "mostSpecific", "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24, "priority": 10}]}`,
+		},
+		{
+			name:       "unknown target selection",
+			content:    `{"apiVersion": "ec2-runtime-checker/v1", "kind": "Config", "targetSelection": "best", "targets": [{"instanceType": "t2.micro", "maxRuntimeHours": 24}]}`,
+			wantFields: []string{"targetSelection"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseFile("config.json", []byte(tt.content), &Config{})
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a ValidationError, got %v", err)
+			}
+			var fields []string
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("Expected errors for %v, got %v", tt.wantFields, validationErr.Errors)
+			}
+		})
+	}
+}
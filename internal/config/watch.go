@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// WatchTargets polls the targets config file every interval until ctx is done and calls
+// onChange with the new targets whenever its content changes and still validates.
+// An invalid file is logged and ignored, so the caller keeps its last good targets.
+// Global settings in the file are only read at startup, so targets may only route to the
+// notifiers the checker started with.
+//
+// The file is read through its path on every poll, so the Kubernetes ConfigMap update
+// pattern of atomically swapping the ..data symlink is picked up like an in-place edit.
+func WatchTargets(ctx context.Context, path string, interval time.Duration, notifiers Notifiers, onChange func([]Target)) {
+	last, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("Failed to read config file for watching", "path", path, "error", err)
+	}
+	// The last valid content, so reading a half-written file in between does not count as a change
+	good := last
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// Mid-swap or removed, keep polling with the last good config
+			slog.Error("Failed to read config file, keeping last good config", "path", path, "error", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		// Remembered even when invalid so the same bad content is only reported once
+		last = data
+
+		file := &Config{}
+		err = parseFile(path, data, file)
+		if err == nil {
+			validation := &ValidationError{}
+			validateRoutes(file.Targets, notifiers, validation)
+			err = validation.err()
+		}
+		if err != nil {
+			slog.Error("Rejected config file change, keeping last good config", "path", path, "error", err)
+			continue
+		}
+		if bytes.Equal(data, good) {
+			continue
+		}
+		good = data
+		slog.Info("Config file changed, reloading targets", "path", path, "targets", len(file.Targets))
+		onChange(file.Targets)
+	}
+}
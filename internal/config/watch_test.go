@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfigMapVersion mimics a kubelet ConfigMap update: the new content is written to
+// a fresh directory and the ..data symlink is atomically swapped to point at it
+func writeConfigMapVersion(t *testing.T, dir, version, content string) {
+	t.Helper()
+
+	versionDir := filepath.Join(dir, version)
+	if err := os.Mkdir(versionDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, "config.json"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(version, tmpLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpLink, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchTargets_SymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMapVersion(t, dir, "v1", `[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`)
+	path := filepath.Join(dir, "config.json")
+	if err := os.Symlink(filepath.Join("..data", "config.json"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan []Target, 4)
+	go WatchTargets(ctx, path, 10*time.Millisecond, nil, func(targets []Target) {
+		changes <- targets
+	})
+
+	// Give the watcher time to read the initial content
+	time.Sleep(30 * time.Millisecond)
+
+	writeConfigMapVersion(t, dir, "v2", `[{"instanceType": "t3.micro", "maxRuntimeHours": 48}]`)
+	select {
+	case targets := <-changes:
+		if len(targets) != 1 || targets[0].InstanceType != "t3.micro" {
+			t.Errorf("Expected the swapped targets, got %+v", targets)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change after the symlink swap")
+	}
+
+	writeConfigMapVersion(t, dir, "v3", `[{"instanceType": "t3.micro", "action": "explode"}]`)
+	select {
+	case targets := <-changes:
+		t.Fatalf("Expected the invalid change to be rejected, got %+v", targets)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	writeConfigMapVersion(t, dir, "v4", `[{"instanceType": "m5.large", "maxRuntimeHours": 8}]`)
+	select {
+	case targets := <-changes:
+		if len(targets) != 1 || targets[0].InstanceType != "m5.large" {
+			t.Errorf("Expected the fixed targets, got %+v", targets)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change after fixing the config")
+	}
+}
+
+func TestWatchTargets_UnchangedContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		// Rewriting identical content must not count as a change
+		os.WriteFile(path, content, 0o644)
+	}()
+	WatchTargets(ctx, path, 10*time.Millisecond, nil, func([]Target) { calls++ })
+
+	if calls != 0 {
+		t.Errorf("Expected no reloads, got %d", calls)
+	}
+}
+
+func TestWatchTargets_UnknownNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan []Target, 4)
+	notifiers := Notifiers{{Name: "slack", Type: NotifierSlack}}
+	go WatchTargets(ctx, path, 10*time.Millisecond, notifiers, func(targets []Target) {
+		changes <- targets
+	})
+
+	// Give the watcher time to read the initial content
+	time.Sleep(30 * time.Millisecond)
+
+	if err := os.WriteFile(path, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "notify": ["slakc"]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case targets := <-changes:
+		t.Fatalf("Expected the route to an unknown notifier to be rejected, got %+v", targets)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"instanceType": "t2.micro", "maxRuntimeHours": 24, "notify": ["slack"]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case targets := <-changes:
+		if len(targets) != 1 || len(targets[0].Notify) != 1 || targets[0].Notify[0] != "slack" {
+			t.Errorf("Expected the routed targets, got %+v", targets)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change after fixing the route")
+	}
+}
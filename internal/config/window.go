@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Time zones for images without a zoneinfo database
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring period in which a target's instances may run, given either as
+// days and a time of day range or as a cron expression
+type Window struct {
+	// Days the window opens on, as names or ranges (optional, defaults to every day)
+	// Example: ["Mon-Fri"], ["Sat", "Sun"]
+	Days []string `json:"days,omitempty"`
+
+	// Time of day the window opens and closes, as HH:MM. An end before the start closes
+	// the window on the next day; 24:00 closes it at midnight.
+	// Example: "08:00", "20:00"
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+
+	// Cron expression matching every minute of the window, instead of days, start and end
+	// Example: "* 8-19 * * 1-5"
+	Cron string `json:"cron,omitempty"`
+
+	// IANA time zone of the window (optional, defaults to UTC)
+	// Example: "Europe/Berlin"
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// String describes the window, e.g. Mon-Fri 08:00-20:00 Europe/Berlin
+func (w Window) String() string {
+	zone := w.TimeZone
+	if zone == "" {
+		zone = "UTC"
+	}
+	if w.Cron != "" {
+		return fmt.Sprintf("cron %q %s", w.Cron, zone)
+	}
+	days := "daily"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ",")
+	}
+	return fmt.Sprintf("%s %s-%s %s", days, w.Start, w.End, zone)
+}
+
+// RunWindows are the windows in which a target's instances may run, any of them allowing it
+type RunWindows []Window
+
+// String describes the windows, e.g. Mon-Fri 08:00-20:00 UTC or Sat 10:00-14:00 UTC
+func (r RunWindows) String() string {
+	descriptions := make([]string, len(r))
+	for i, w := range r {
+		descriptions[i] = w.String()
+	}
+	return strings.Join(descriptions, " or ")
+}
+
+// Allow reports whether instances may run at t, which they always may without windows.
+// Invalid windows, which validation rejects, allow running rather than acting on instances.
+func (r RunWindows) Allow(t time.Time) bool {
+	if len(r) == 0 {
+		return true
+	}
+	for _, w := range r {
+		parsed, err := w.parse()
+		if err != nil || parsed.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClosesAt returns the first minute from t on at which instances may no longer run, t itself
+// when they may not run at t. It reports false when there are no windows or they stay open
+// for the coming week.
+func (r RunWindows) ClosesAt(t time.Time) (time.Time, bool) {
+	if len(r) == 0 {
+		return time.Time{}, false
+	}
+	if !r.Allow(t) {
+		return t, true
+	}
+	for minute := t.Truncate(time.Minute).Add(time.Minute); minute.Sub(t) <= 7*24*time.Hour; minute = minute.Add(time.Minute) {
+		if !r.Allow(minute) {
+			return minute, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parsedWindow is a window ready to be evaluated
+type parsedWindow struct {
+	location *time.Location
+	schedule cron.Schedule
+
+	days       [7]bool
+	start, end int // Minutes since midnight
+}
+
+// contains reports whether the window is open at t
+func (p *parsedWindow) contains(t time.Time) bool {
+	t = t.In(p.location)
+	if p.schedule != nil {
+		minute := t.Truncate(time.Minute)
+		return p.schedule.Next(minute.Add(-time.Second)).Equal(minute)
+	}
+
+	now, day := t.Hour()*60+t.Minute(), t.Weekday()
+	if p.start < p.end {
+		return p.days[day] && now >= p.start && now < p.end
+	}
+	// Spanning midnight, the window belongs to the day it opened on
+	if now >= p.start {
+		return p.days[day]
+	}
+	return now < p.end && p.days[(day+6)%7]
+}
+
+// windowCache holds the parsed windows by description, so time zones and cron expressions
+// are not loaded again for every instance
+var windowCache sync.Map
+
+// parse returns the window ready to be evaluated
+func (w Window) parse() (*parsedWindow, error) {
+	key := w.String()
+	if parsed, ok := windowCache.Load(key); ok {
+		return parsed.(*parsedWindow), nil
+	}
+
+	var parsed parsedWindow
+	var err error
+	if parsed.location, err = loadTimeZone(w.TimeZone); err != nil {
+		return nil, err
+	}
+	if w.Cron != "" {
+		if parsed.schedule, err = cron.ParseStandard(w.Cron); err != nil {
+			return nil, err
+		}
+	} else {
+		if parsed.days, err = parseDays(w.Days); err != nil {
+			return nil, err
+		}
+		if parsed.start, err = parseClock(w.Start, false); err != nil {
+			return nil, err
+		}
+		if parsed.end, err = parseClock(w.End, true); err != nil {
+			return nil, err
+		}
+	}
+
+	windowCache.Store(key, &parsed)
+	return &parsed, nil
+}
+
+// loadTimeZone loads an IANA time zone, the empty zone meaning UTC
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// parseDays returns the days selected by day names and ranges such as Mon-Fri or Fri-Mon,
+// every day when there are none
+func parseDays(days []string) ([7]bool, error) {
+	var selected [7]bool
+	if len(days) == 0 {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, value := range days {
+		first, last, isRange := strings.Cut(value, "-")
+		if !isRange {
+			last = first
+		}
+		from, fromOK := weekday(first)
+		to, toOK := weekday(last)
+		if !fromOK || !toOK {
+			return selected, fmt.Errorf("invalid day %q, expected Mon to Sun or a range such as Mon-Fri", value)
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			selected[day] = true
+			if day == to {
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+// weekday returns the day of a name such as Mon or Monday, in any case
+func weekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if full := strings.ToLower(day.String()); name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// parseClock returns the minutes since midnight of an HH:MM time of day. Only the end of a
+// window may be 24:00.
+func parseClock(value string, end bool) (int, error) {
+	hh, mm, ok := strings.Cut(value, ":")
+	hour, hourErr := strconv.Atoi(hh)
+	minute, minuteErr := strconv.Atoi(mm)
+	if !ok || len(hh) != 2 || len(mm) != 2 || hourErr != nil || minuteErr != nil || hour < 0 || minute < 0 || minute > 59 ||
+		hour > 24 || (hour == 24 && (minute != 0 || !end)) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// validateWindows records every problem with the run windows of a target
+func validateWindows(windows RunWindows, field string, validation *ValidationError) {
+	for i, w := range windows {
+		field := fmt.Sprintf("%s.runWindows[%d]", field, i)
+
+		if _, err := loadTimeZone(w.TimeZone); err != nil {
+			validation.add(field+".timeZone", "unknown time zone %q", w.TimeZone)
+		}
+
+		if w.Cron != "" {
+			if w.Start != "" || w.End != "" || len(w.Days) > 0 {
+				validation.add(field, "must set either cron or days, start and end, not both")
+			}
+			if _, err := cron.ParseStandard(w.Cron); err != nil {
+				validation.add(field+".cron", "invalid cron expression %q: %v", w.Cron, err)
+			}
+			continue
+		}
+
+		if w.Start == "" || w.End == "" {
+			validation.add(field, "must set start and end, or cron")
+			continue
+		}
+		if _, err := parseDays(w.Days); err != nil {
+			validation.add(field+".days", "%v", err)
+		}
+		start, err := parseClock(w.Start, false)
+		if err != nil {
+			validation.add(field+".start", "%v", err)
+		}
+		end, endErr := parseClock(w.End, true)
+		if endErr != nil {
+			validation.add(field+".end", "%v", endErr)
+		}
+		if err == nil && endErr == nil && start == end {
+			validation.add(field+".end", "must differ from start")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunWindowsAllow(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	businessHours := Window{Days: []string{"Mon-Fri"}, Start: "08:00", End: "20:00", TimeZone: "Europe/Berlin"}
+	// 2026-03-02 is a Monday
+	monday := func(hour, minute int) time.Time { return time.Date(2026, 3, 2, hour, minute, 0, 0, berlin) }
+
+	tests := []struct {
+		name    string
+		windows RunWindows
+		at      time.Time
+		want    bool
+	}{
+		{name: "no windows", at: monday(3, 0), want: true},
+		{name: "inside business hours", windows: RunWindows{businessHours}, at: monday(8, 0), want: true},
+		{name: "at the end of business hours", windows: RunWindows{businessHours}, at: monday(20, 0), want: false},
+		{name: "before business hours", windows: RunWindows{businessHours}, at: monday(7, 59), want: false},
+		{name: "business hours in UTC", windows: RunWindows{businessHours}, at: time.Date(2026, 3, 2, 18, 30, 0, 0, time.UTC), want: true},
+		{name: "on a weekend", windows: RunWindows{businessHours}, at: monday(12, 0).AddDate(0, 0, -1), want: false},
+		{name: "wrapping day range", windows: RunWindows{{Days: []string{"fri-mon"}, Start: "00:00", End: "24:00"}}, at: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), want: true},
+		{name: "overnight after the start", windows: RunWindows{{Days: []string{"Mon"}, Start: "22:00", End: "06:00"}}, at: time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC), want: true},
+		{name: "overnight on the next day", windows: RunWindows{{Days: []string{"Mon"}, Start: "22:00", End: "06:00"}}, at: time.Date(2026, 3, 3, 5, 0, 0, 0, time.UTC), want: true},
+		{name: "overnight opened on an unlisted day", windows: RunWindows{{Days: []string{"Mon"}, Start: "22:00", End: "06:00"}}, at: time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC), want: false},
+		{name: "inside cron window", windows: RunWindows{{Cron: "* 8-19 * * 1-5", TimeZone: "Europe/Berlin"}}, at: monday(19, 59), want: true},
+		{name: "outside cron window", windows: RunWindows{{Cron: "* 8-19 * * 1-5", TimeZone: "Europe/Berlin"}}, at: monday(20, 0), want: false},
+		{name: "any window allows", windows: RunWindows{businessHours, {Days: []string{"Sun"}, Start: "10:00", End: "14:00", TimeZone: "Europe/Berlin"}}, at: monday(11, 0).AddDate(0, 0, -1), want: true},
+		{name: "invalid window allows", windows: RunWindows{{Start: "8am", End: "8pm"}}, at: monday(3, 0), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.windows.Allow(tt.at); got != tt.want {
+				t.Errorf("Allow(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWindowsClosesAt(t *testing.T) {
+	businessHours := RunWindows{{Days: []string{"Mon-Fri"}, Start: "08:00", End: "20:00"}}
+	monday := time.Date(2026, 3, 2, 10, 30, 15, 0, time.UTC)
+
+	if closesAt, ok := businessHours.ClosesAt(monday); !ok || !closesAt.Equal(time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the window to close at 20:00, got %v %v", closesAt, ok)
+	}
+	if closesAt, ok := businessHours.ClosesAt(monday.Add(12 * time.Hour)); !ok || !closesAt.Equal(monday.Add(12*time.Hour)) {
+		t.Errorf("Expected a closed window to be due right away, got %v %v", closesAt, ok)
+	}
+	if _, ok := (RunWindows{{Start: "00:00", End: "24:00"}}).ClosesAt(monday); ok {
+		t.Error("Expected a window open all week never to close")
+	}
+	if _, ok := RunWindows(nil).ClosesAt(monday); ok {
+		t.Error("Expected no windows never to close")
+	}
+}
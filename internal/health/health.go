@@ -0,0 +1,109 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Health tracks scheduler and leader state for the liveness and readiness probes
+type Health struct {
+	mu         sync.Mutex
+	schedule   cron.Schedule
+	missedRuns int
+	leading    bool
+	// lastProgress is when this replica last completed a check or started leading
+	lastProgress time.Time
+
+	now func() time.Time
+}
+
+// New returns a Health for the cron schedule that fails liveness after missedRuns
+// scheduled checks in a row did not complete
+func New(schedule string, missedRuns int) (*Health, error) {
+	if missedRuns < 1 {
+		return nil, fmt.Errorf("missed runs must be at least 1, got %d", missedRuns)
+	}
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", schedule, err)
+	}
+	return &Health{schedule: parsed, missedRuns: missedRuns, now: time.Now}, nil
+}
+
+// SetLeader records whether this replica holds the lease and runs the checks
+func (h *Health) SetLeader(leading bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if leading && !h.leading {
+		// Checks are only expected from the moment the replica starts leading
+		h.lastProgress = h.now()
+	}
+	h.leading = leading
+}
+
+// RunCompleted records that a check completed
+func (h *Health) RunCompleted() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastProgress = h.now()
+}
+
+// Live returns an error when this replica leads but missed too many scheduled checks.
+// A replica waiting for the lease is always live.
+func (h *Health) Live() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.leading {
+		return nil
+	}
+
+	deadline := h.lastProgress
+	for range h.missedRuns {
+		deadline = h.schedule.Next(deadline)
+	}
+	if h.now().After(deadline) {
+		return fmt.Errorf("no check completed since %s, %d scheduled runs missed", h.lastProgress.UTC().Format(time.RFC3339), h.missedRuns)
+	}
+	return nil
+}
+
+// Ready returns an error when this replica does not hold the lease
+func (h *Health) Ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.leading {
+		return fmt.Errorf("not the leader")
+	}
+	return nil
+}
+
+// LivenessHandler serves Live as a probe endpoint
+func (h *Health) LivenessHandler() http.Handler {
+	return probeHandler(h.Live)
+}
+
+// ReadinessHandler serves Ready as a probe endpoint
+func (h *Health) ReadinessHandler() http.Handler {
+	return probeHandler(h.Ready)
+}
+
+// probeHandler responds 200 when check passes and 503 with the error otherwise
+func probeHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		schedule   string
+		missedRuns int
+		wantErr    bool
+	}{
+		{name: "standard schedule", schedule: "*/5 * * * *", missedRuns: 3},
+		{name: "descriptor", schedule: "@every 10m", missedRuns: 1},
+		{name: "invalid schedule", schedule: "every five minutes", missedRuns: 3, wantErr: true},
+		{name: "zero missed runs", schedule: "*/5 * * * *", missedRuns: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.schedule, tt.missedRuns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLive(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		leading  bool
+		complete time.Duration // Offset of the last completed check from start, negative for none
+		elapsed  time.Duration
+		wantLive bool
+	}{
+		{name: "follower is always live", leading: false, complete: -1, elapsed: 24 * time.Hour, wantLive: true},
+		{name: "new leader within missed runs", leading: true, complete: -1, elapsed: 14 * time.Minute, wantLive: true},
+		{name: "new leader without any check", leading: true, complete: -1, elapsed: 16 * time.Minute, wantLive: false},
+		{name: "recent check", leading: true, complete: 20 * time.Minute, elapsed: 30 * time.Minute, wantLive: true},
+		{name: "checks stopped", leading: true, complete: 20 * time.Minute, elapsed: 40 * time.Minute, wantLive: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New("*/5 * * * *", 3)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			now := start
+			h.now = func() time.Time { return now }
+
+			h.SetLeader(tt.leading)
+			if tt.complete >= 0 {
+				now = start.Add(tt.complete)
+				h.RunCompleted()
+			}
+			now = start.Add(tt.elapsed)
+
+			if err := h.Live(); (err == nil) != tt.wantLive {
+				t.Errorf("Live() = %v, want live=%v", err, tt.wantLive)
+			}
+		})
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	h, err := New("*/5 * * * *", 3)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	serve := func(handler http.Handler) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec
+	}
+
+	if rec := serve(h.ReadinessHandler()); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "not the leader") {
+		t.Errorf("Expected follower to be unready, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h.LivenessHandler()); rec.Code != http.StatusOK {
+		t.Errorf("Expected follower to be live, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	h.SetLeader(true)
+	if rec := serve(h.ReadinessHandler()); rec.Code != http.StatusOK {
+		t.Errorf("Expected leader to be ready, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	h.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if rec := serve(h.LivenessHandler()); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected stalled leader to fail liveness, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "ec2_checker"
+
+// describeBuckets are the DescribeInstances latency histogram buckets in seconds
+var describeBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics holds the checker's Prometheus metrics and serves them from its own registry
+type Metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	instancesScanned  *prometheus.CounterVec
+	instancesMatched  *prometheus.CounterVec
+	actionsAttempted  *prometheus.CounterVec
+	actions           *prometheus.CounterVec
+	describeDuration  *prometheus.HistogramVec
+	describeErrors    *prometheus.CounterVec
+	runs              *prometheus.CounterVec
+	lastRun           prometheus.Gauge
+	lastSuccessfulRun prometheus.Gauge
+	leader            prometheus.Gauge
+}
+
+// New returns Metrics with every metric registered and no samples
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		instancesScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "instances_scanned_total", Help: "Instances returned by DescribeInstances.",
+		}, []string{"account", "region"}),
+		instancesMatched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "instances_matched_total", Help: "Instances that matched a target.",
+		}, []string{"target", "decision"}),
+		actionsAttempted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "actions_attempted_total", Help: "Actions the checker tried to apply, outside dry run.",
+		}, []string{"action"}),
+		actions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "actions_total", Help: "Actions by outcome; dry-run counts actions that would have been applied.",
+		}, []string{"action", "outcome"}),
+		describeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "describe_instances_duration_seconds", Help: "Latency of DescribeInstances queries.", Buckets: describeBuckets,
+		}, []string{"account", "region"}),
+		describeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "describe_instances_errors_total", Help: "DescribeInstances queries that failed.",
+		}, []string{"account", "region"}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "runs_total", Help: "Completed checks by result.",
+		}, []string{"result"}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_run_timestamp_seconds", Help: "Unix time the last check completed.",
+		}),
+		lastSuccessfulRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_successful_run_timestamp_seconds", Help: "Unix time the last check without errors completed.",
+		}),
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "leader", Help: "Whether this replica runs the checks (1) or waits for the lease (0).",
+		}),
+	}
+	m.registry.MustRegister(
+		m.instancesScanned, m.instancesMatched, m.actionsAttempted, m.actions, m.describeDuration,
+		m.describeErrors, m.runs, m.lastRun, m.lastSuccessfulRun, m.leader,
+	)
+	m.handler = promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return m
+}
+
+// RecordRun updates the metrics from the report of a completed check
+func (m *Metrics) RecordRun(report *checker.RunReport) {
+	for _, scope := range report.Scopes {
+		m.instancesScanned.WithLabelValues(scope.AccountID, scope.Region).Add(float64(scope.Scanned))
+		m.describeErrors.WithLabelValues(scope.AccountID, scope.Region).Add(float64(len(scope.Errors)))
+		for _, duration := range scope.DescribeDurations {
+			m.describeDuration.WithLabelValues(scope.AccountID, scope.Region).Observe(duration.Seconds())
+		}
+	}
+
+	for _, instance := range report.Instances {
+		m.instancesMatched.WithLabelValues(strconv.Itoa(instance.TargetIndex), string(instance.Decision)).Inc()
+		if instance.Decision != checker.DecisionAct {
+			continue
+		}
+		action := string(instance.Action)
+		if instance.Outcome == checker.OutcomeSucceeded || instance.Outcome == checker.OutcomeFailed {
+			m.actionsAttempted.WithLabelValues(action).Inc()
+		}
+		m.actions.WithLabelValues(action, string(instance.Outcome)).Inc()
+	}
+
+	finishedAt := float64(report.FinishedAt.UnixNano()) / 1e9
+	m.lastRun.Set(finishedAt)
+	if report.Failed() {
+		m.runs.WithLabelValues("failed").Inc()
+	} else {
+		m.runs.WithLabelValues("succeeded").Inc()
+		m.lastSuccessfulRun.Set(finishedAt)
+	}
+}
+
+// SetLeader records whether this replica holds the lease
+func (m *Metrics) SetLeader(leader bool) {
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	m.leader.Set(value)
+}
+
+// ServeHTTP serves all metrics in the format the scraper negotiates
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
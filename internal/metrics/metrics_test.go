@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/checker"
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestRecordRun(t *testing.T) {
+	finishedAt := time.Unix(1700000000, 0)
+	report := &checker.RunReport{
+		StartedAt:  finishedAt.Add(-time.Second),
+		FinishedAt: finishedAt,
+		Scopes: []checker.ScopeReport{
+			{Region: "us-east-1", Scanned: 5, DescribeDurations: []time.Duration{200 * time.Millisecond, 2 * time.Second}},
+			{AccountID: "111111111111", Region: "us-east-1", Errors: []string{"AccessDenied"}, DescribeDurations: []time.Duration{time.Second}},
+		},
+		Instances: []checker.InstanceReport{
+			{TargetIndex: 0, Action: config.ActionTerminate, Decision: checker.DecisionAct, Outcome: checker.OutcomeSucceeded},
+			{TargetIndex: 0, Action: config.ActionTerminate, Decision: checker.DecisionAct, Outcome: checker.OutcomeFailed},
+			{TargetIndex: 1, Action: config.ActionStop, Decision: checker.DecisionAct, Outcome: checker.OutcomeDryRun},
+			{TargetIndex: 1, Action: config.ActionStop, Decision: checker.DecisionNone, Outcome: checker.OutcomeNone},
+		},
+	}
+
+	m := New()
+	m.RecordRun(report)
+	m.SetLeader(true)
+
+	// Dry-run actions do not count as attempted, and a failed run leaves the successful run timestamp unset
+	expected := `
+# HELP ec2_checker_instances_scanned_total Instances returned by DescribeInstances.
+# TYPE ec2_checker_instances_scanned_total counter
+ec2_checker_instances_scanned_total{account="",region="us-east-1"} 5
+ec2_checker_instances_scanned_total{account="111111111111",region="us-east-1"} 0
+# HELP ec2_checker_instances_matched_total Instances that matched a target.
+# TYPE ec2_checker_instances_matched_total counter
+ec2_checker_instances_matched_total{decision="act",target="0"} 2
+ec2_checker_instances_matched_total{decision="act",target="1"} 1
+ec2_checker_instances_matched_total{decision="none",target="1"} 1
+# HELP ec2_checker_actions_attempted_total Actions the checker tried to apply, outside dry run.
+# TYPE ec2_checker_actions_attempted_total counter
+ec2_checker_actions_attempted_total{action="terminate"} 2
+# HELP ec2_checker_actions_total Actions by outcome; dry-run counts actions that would have been applied.
+# TYPE ec2_checker_actions_total counter
+ec2_checker_actions_total{action="stop",outcome="dry-run"} 1
+ec2_checker_actions_total{action="terminate",outcome="failed"} 1
+ec2_checker_actions_total{action="terminate",outcome="succeeded"} 1
+# HELP ec2_checker_describe_instances_errors_total DescribeInstances queries that failed.
+# TYPE ec2_checker_describe_instances_errors_total counter
+ec2_checker_describe_instances_errors_total{account="",region="us-east-1"} 0
+ec2_checker_describe_instances_errors_total{account="111111111111",region="us-east-1"} 1
+# HELP ec2_checker_describe_instances_duration_seconds Latency of DescribeInstances queries.
+# TYPE ec2_checker_describe_instances_duration_seconds histogram
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="0.05"} 0
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="0.1"} 0
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="0.25"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="0.5"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="1"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="2.5"} 2
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="5"} 2
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="10"} 2
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="30"} 2
+ec2_checker_describe_instances_duration_seconds_bucket{account="",region="us-east-1",le="+Inf"} 2
+ec2_checker_describe_instances_duration_seconds_sum{account="",region="us-east-1"} 2.2
+ec2_checker_describe_instances_duration_seconds_count{account="",region="us-east-1"} 2
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="0.05"} 0
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="0.1"} 0
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="0.25"} 0
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="0.5"} 0
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="1"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="2.5"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="5"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="10"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="30"} 1
+ec2_checker_describe_instances_duration_seconds_bucket{account="111111111111",region="us-east-1",le="+Inf"} 1
+ec2_checker_describe_instances_duration_seconds_sum{account="111111111111",region="us-east-1"} 1
+ec2_checker_describe_instances_duration_seconds_count{account="111111111111",region="us-east-1"} 1
+# HELP ec2_checker_runs_total Completed checks by result.
+# TYPE ec2_checker_runs_total counter
+ec2_checker_runs_total{result="failed"} 1
+# HELP ec2_checker_last_run_timestamp_seconds Unix time the last check completed.
+# TYPE ec2_checker_last_run_timestamp_seconds gauge
+ec2_checker_last_run_timestamp_seconds 1.7e+09
+# HELP ec2_checker_last_successful_run_timestamp_seconds Unix time the last check without errors completed.
+# TYPE ec2_checker_last_successful_run_timestamp_seconds gauge
+ec2_checker_last_successful_run_timestamp_seconds 0
+# HELP ec2_checker_leader Whether this replica runs the checks (1) or waits for the lease (0).
+# TYPE ec2_checker_leader gauge
+ec2_checker_leader 1
+`
+	if err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	m := New()
+	m.RecordRun(&checker.RunReport{FinishedAt: time.Unix(1700000000, 0)})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Expected the text exposition content type, got %q", got)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(rec.Body)
+	if err != nil {
+		t.Fatalf("Expected a valid exposition, got %v", err)
+	}
+	family, ok := families["ec2_checker_last_successful_run_timestamp_seconds"]
+	if !ok {
+		t.Fatal("Expected the successful run timestamp to be served")
+	}
+	if got := family.GetMetric()[0].GetGauge().GetValue(); got != 1.7e9 {
+		t.Errorf("Expected the successful run timestamp 1.7e9, got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// Message is a notification about a run
+type Message struct {
+	Subject string
+	// Body is the report rendered in Format
+	Body   string
+	Format config.MessageFormat
+	// HTML is the report rendered as HTML, sent alongside Body by email notifiers (optional)
+	HTML string
+	// To overrides the recipients of email notifiers (optional)
+	To []string
+	// Data is the structured report, sent as is by the webhook notifier
+	Data any
+}
+
+// Notifier sends notifications to one destination
+type Notifier interface {
+	// Name identifies the notifier in target routing and logs
+	Name() string
+	Notify(ctx context.Context, message Message) error
+}
+
+// OwnerNotifier is a notifier that can also send personal messages to the owners of instances
+type OwnerNotifier interface {
+	Notifier
+	// NotifyOwner sends the message to the owner, as named by the owner tag of their instances
+	NotifyOwner(ctx context.Context, owner string, message Message) error
+}
+
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// httpClient sends the webhook requests of every notifier
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// New returns the notifier for its config. The SNS client is only used by sns notifiers.
+func New(cfg config.Notifier, snsClient SNSAPI) (Notifier, error) {
+	switch cfg.Type {
+	case config.NotifierSNS:
+		if snsClient == nil {
+			return nil, fmt.Errorf("notifier %q: no SNS client", cfg.Name)
+		}
+		return NewSNS(cfg.Name, snsClient, cfg.TopicArn), nil
+	case config.NotifierSlack:
+		return NewSlack(cfg.Name, cfg.URL, cfg.Token), nil
+	case config.NotifierTeams:
+		return NewTeams(cfg.Name, cfg.URL), nil
+	case config.NotifierWebhook:
+		return NewWebhook(cfg.Name, cfg.URL, cfg.Headers), nil
+	case config.NotifierSMTP:
+		return NewSMTP(cfg.Name, SMTPOptions{
+			Addr:     cfg.Host,
+			From:     cfg.From,
+			To:       cfg.To,
+			TLS:      cfg.TLS,
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}), nil
+	}
+	return nil, fmt.Errorf("notifier %q: unknown type %q", cfg.Name, cfg.Type)
+}
+
+// postJSON posts the payload as JSON to the endpoint, failing on any status other than 2xx
+func postJSON(ctx context.Context, endpoint string, headers map[string]string, payload any) error {
+	return doJSON(ctx, http.MethodPost, endpoint, headers, payload, nil)
+}
+
+// doJSON sends a request with the payload as JSON, if any, failing on any status other than
+// 2xx, and decodes the JSON response into result, if any
+func doJSON(ctx context.Context, method, endpoint string, headers map[string]string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode payload: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The URL usually holds the webhook's secret, so it is left out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// MockSNSClient
+type MockSNSClient struct {
+	PublishFunc func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+func (m *MockSNSClient) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	return m.PublishFunc(ctx, params, optFns...)
+}
+
+// request is what a test server received
+type request struct {
+	method  string
+	headers http.Header
+	body    map[string]any
+}
+
+// newServer returns a test server answering with the status and recording every request
+func newServer(t *testing.T, status int) (*httptest.Server, *[]request) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Expected a JSON body, got %q", data)
+		}
+		requests = append(requests, request{method: r.Method, headers: r.Header, body: body})
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_payload"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.Notifier
+		sns     SNSAPI
+		want    string
+		wantErr bool
+	}{
+		{name: "sns", config: config.Notifier{Name: "ops", Type: config.NotifierSNS, TopicArn: "arn:aws:sns:us-east-1:123456789012:ops"}, sns: &MockSNSClient{}, want: "*notifier.SNS"},
+		{name: "sns without client", config: config.Notifier{Name: "ops", Type: config.NotifierSNS}, wantErr: true},
+		{name: "slack", config: config.Notifier{Name: "dev", Type: config.NotifierSlack, URL: "https://hooks.slack.com/x"}, want: "*notifier.Slack"},
+		{name: "teams", config: config.Notifier{Name: "dev", Type: config.NotifierTeams, URL: "https://example.com/x"}, want: "*notifier.Teams"},
+		{name: "webhook", config: config.Notifier{Name: "dev", Type: config.NotifierWebhook, URL: "https://example.com/x"}, want: "*notifier.Webhook"},
+		{name: "smtp", config: config.Notifier{Name: "mail", Type: config.NotifierSMTP, Host: "localhost:25", From: "checker@example.com", To: []string{"ops@example.com"}}, want: "*notifier.SMTP"},
+		{name: "unknown type", config: config.Notifier{Name: "dev", Type: "pager"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := New(tt.config, tt.sns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (fmt.Sprintf("%T", n) != tt.want || n.Name() != tt.config.Name) {
+				t.Errorf("Expected %s named %s, got %s named %s", tt.want, tt.config.Name, fmt.Sprintf("%T", n), n.Name())
+			}
+		})
+	}
+}
+
+func TestSNS_Notify(t *testing.T) {
+	var published *sns.PublishInput
+	client := &MockSNSClient{
+		PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+			published = params
+			return &sns.PublishOutput{}, nil
+		},
+	}
+
+	err := NewSNS("ops", client, "arn:aws:sns:us-east-1:123456789012:ops").Notify(context.Background(), Message{Subject: "Alert", Body: "- ID: i-1"})
+	if err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+	if aws.ToString(published.TopicArn) != "arn:aws:sns:us-east-1:123456789012:ops" || aws.ToString(published.Subject) != "Alert" || aws.ToString(published.Message) != "- ID: i-1" {
+		t.Errorf("Unexpected publish input %+v", published)
+	}
+}
+
+func TestSNS_Subject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{name: "short", subject: "Alert", want: "Alert"},
+		{name: "exactly the limit", subject: strings.Repeat("a", 100), want: strings.Repeat("a", 100)},
+		{name: "too long", subject: strings.Repeat("a", 150), want: strings.Repeat("a", 97) + "..."},
+		{name: "multi-byte characters", subject: strings.Repeat("é", 101), want: strings.Repeat("é", 97) + "..."},
+		{name: "line breaks", subject: "3 instances\nstopped\n", want: "3 instances stopped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var published *sns.PublishInput
+			client := &MockSNSClient{
+				PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+					published = params
+					return &sns.PublishOutput{}, nil
+				},
+			}
+
+			if err := NewSNS("ops", client, "arn:aws:sns:us-east-1:123456789012:ops").Notify(context.Background(), Message{Subject: tt.subject}); err != nil {
+				t.Fatalf("Notify() failed: %v", err)
+			}
+			if got := aws.ToString(published.Subject); got != tt.want {
+				t.Errorf("Expected subject %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSNS_NotifyOwner(t *testing.T) {
+	var published *sns.PublishInput
+	client := &MockSNSClient{
+		PublishFunc: func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+			published = params
+			return &sns.PublishOutput{}, nil
+		},
+	}
+
+	err := NewSNS("ops", client, "arn:aws:sns:us-east-1:123456789012:ops").NotifyOwner(context.Background(), "alice@example.com", Message{Subject: "Alert"})
+	if err != nil {
+		t.Fatalf("NotifyOwner() failed: %v", err)
+	}
+	owner := published.MessageAttributes[OwnerAttribute]
+	if aws.ToString(owner.DataType) != "String" || aws.ToString(owner.StringValue) != "alice@example.com" {
+		t.Errorf("Expected the owner as message attribute, got %+v", published.MessageAttributes)
+	}
+}
+
+func TestSlack_NotifyOwner(t *testing.T) {
+	var posted []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-token" {
+			w.Write([]byte(`{"ok": false, "error": "invalid_auth"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/users.lookupByEmail":
+			if r.URL.Query().Get("email") != "alice@example.com" {
+				w.Write([]byte(`{"ok": false, "error": "users_not_found"}`))
+				return
+			}
+			w.Write([]byte(`{"ok": true, "user": {"id": "U0ALICE"}}`))
+		case "/chat.postMessage":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			posted = append(posted, body)
+			w.Write([]byte(`{"ok": true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	slack := NewSlack("dev", "https://hooks.slack.com/x", "xoxb-token")
+	slack.api = server.URL
+	message := Message{Subject: "Alert", Body: "- ID: i-1\n"}
+
+	tests := []struct {
+		owner       string
+		wantChannel string
+		wantErr     bool
+	}{
+		{owner: "alice@example.com", wantChannel: "U0ALICE"},
+		{owner: "U0BOB", wantChannel: "U0BOB"},
+		{owner: "carol@example.com", wantErr: true},
+		{owner: "carol", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.owner, func(t *testing.T) {
+			posted = nil
+			err := slack.NotifyOwner(context.Background(), tt.owner, message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NotifyOwner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(posted) > 0 {
+					t.Errorf("Expected no message, got %v", posted)
+				}
+				return
+			}
+			if len(posted) != 1 || posted[0]["channel"] != tt.wantChannel || posted[0]["text"] != "*Alert*\n- ID: i-1\n" {
+				t.Errorf("Expected a direct message to %s, got %v", tt.wantChannel, posted)
+			}
+		})
+	}
+
+	if err := NewSlack("dev", "https://hooks.slack.com/x", "").NotifyOwner(context.Background(), "U0BOB", message); err == nil {
+		t.Error("Expected an error without a bot token")
+	}
+}
+
+func TestWebhookNotifiers(t *testing.T) {
+	message := Message{Subject: "Alert", Body: "- ID: i-1\n- ID: i-2\n", Format: config.FormatMarkdown, Data: map[string]any{"dryRun": true}}
+
+	tests := []struct {
+		name     string
+		notifier func(url string) Notifier
+		check    func(t *testing.T, req request)
+	}{
+		{
+			name:     "slack",
+			notifier: func(url string) Notifier { return NewSlack("dev", url, "") },
+			check: func(t *testing.T, req request) {
+				if req.body["text"] != "*Alert*\n- ID: i-1\n- ID: i-2\n" {
+					t.Errorf("Unexpected Slack text %q", req.body["text"])
+				}
+			},
+		},
+		{
+			name:     "teams",
+			notifier: func(url string) Notifier { return NewTeams("dev", url) },
+			check: func(t *testing.T, req request) {
+				attachments, _ := req.body["attachments"].([]any)
+				if req.body["type"] != "message" || len(attachments) != 1 {
+					t.Fatalf("Expected a message with one attachment, got %v", req.body)
+				}
+				content := attachments[0].(map[string]any)["content"].(map[string]any)
+				blocks := content["body"].([]any)
+				if content["type"] != "AdaptiveCard" || len(blocks) != 2 {
+					t.Fatalf("Expected an Adaptive Card with two blocks, got %v", content)
+				}
+				if title := blocks[0].(map[string]any)["text"]; title != "Alert" {
+					t.Errorf("Expected the subject as title, got %q", title)
+				}
+				if text := blocks[1].(map[string]any)["text"]; text != "- ID: i-1\n\n- ID: i-2" {
+					t.Errorf("Expected one paragraph per line, got %q", text)
+				}
+			},
+		},
+		{
+			name: "webhook",
+			notifier: func(url string) Notifier {
+				return NewWebhook("audit", url, map[string]string{"Authorization": "Bearer secret"})
+			},
+			check: func(t *testing.T, req request) {
+				if req.headers.Get("Authorization") != "Bearer secret" {
+					t.Errorf("Expected the configured header, got %v", req.headers)
+				}
+				report, _ := req.body["report"].(map[string]any)
+				if req.body["subject"] != "Alert" || req.body["text"] != message.Body || req.body["format"] != "markdown" || report["dryRun"] != true {
+					t.Errorf("Unexpected webhook body %v", req.body)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newServer(t, http.StatusOK)
+			if err := tt.notifier(server.URL).Notify(context.Background(), message); err != nil {
+				t.Fatalf("Notify() failed: %v", err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("Expected one request, got %d", len(*requests))
+			}
+			req := (*requests)[0]
+			if req.method != http.MethodPost || req.headers.Get("Content-Type") != "application/json" {
+				t.Errorf("Expected a JSON POST, got %s %s", req.method, req.headers.Get("Content-Type"))
+			}
+			tt.check(t, req)
+		})
+	}
+}
+
+func TestWebhookNotifiers_Errors(t *testing.T) {
+	server, _ := newServer(t, http.StatusBadRequest)
+	err := NewSlack("dev", server.URL, "").Notify(context.Background(), Message{Subject: "Alert"})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("Expected the status and response in the error, got %v", err)
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	url := closed.URL + "/services/secret-token"
+	closed.Close()
+	err = NewWebhook("audit", url, nil).Notify(context.Background(), Message{Subject: "Alert"})
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected an error without the URL, got %v", err)
+	}
+}
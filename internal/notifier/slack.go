@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// slackAPI is the base URL of the Slack Web API
+const slackAPI = "https://slack.com/api"
+
+// slackUserID matches Slack user IDs, which owners may be named by instead of their email
+var slackUserID = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// Slack posts notifications to a Slack incoming webhook, and direct messages to owners
+// through the Web API when it has a bot token
+type Slack struct {
+	name  string
+	url   string
+	token string
+	api   string
+}
+
+// NewSlack returns a notifier posting to the incoming webhook URL. The bot token, which may
+// be empty, needs the users:read.email and chat:write scopes to notify owners.
+func NewSlack(name, url, token string) *Slack {
+	return &Slack{name: name, url: url, token: token, api: slackAPI}
+}
+
+func (n *Slack) Name() string {
+	return n.name
+}
+
+// slackPayload is the body of an incoming webhook request
+type slackPayload struct {
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
+}
+
+// slackResponse is the body of a Web API response
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	User  struct {
+		ID string `json:"id"`
+	} `json:"user"`
+}
+
+// Notify posts the report with the subject in bold
+func (n *Slack) Notify(ctx context.Context, message Message) error {
+	return postJSON(ctx, n.url, nil, slackPayload{Text: slackText(message)})
+}
+
+// NotifyOwner sends the report as a direct message to the owner, named by their email
+// address or Slack user ID
+func (n *Slack) NotifyOwner(ctx context.Context, owner string, message Message) error {
+	if n.token == "" {
+		return fmt.Errorf("no bot token to message owner %q", owner)
+	}
+
+	userID := owner
+	if strings.Contains(owner, "@") {
+		var user slackResponse
+		if err := n.call(ctx, http.MethodGet, "users.lookupByEmail?email="+url.QueryEscape(owner), nil, &user); err != nil {
+			return fmt.Errorf("failed to look up owner %q: %w", owner, err)
+		}
+		userID = user.User.ID
+	} else if !slackUserID.MatchString(owner) {
+		return fmt.Errorf("owner %q is neither an email address nor a Slack user ID", owner)
+	}
+
+	// Posting to a user ID delivers the message in the app's direct messages with the user
+	var posted slackResponse
+	return n.call(ctx, http.MethodPost, "chat.postMessage", slackPayload{Channel: userID, Text: slackText(message)}, &posted)
+}
+
+// call calls a Web API method with the bot token, failing when Slack does not report success
+func (n *Slack) call(ctx context.Context, method, path string, payload any, result *slackResponse) error {
+	headers := map[string]string{"Authorization": "Bearer " + n.token}
+	if err := doJSON(ctx, method, n.api+"/"+path, headers, payload, result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("slack API error: %s", result.Error)
+	}
+	return nil
+}
+
+// slackText returns the message text, with the subject in bold
+func slackText(message Message) string {
+	return "*" + message.Subject + "*\n" + message.Body
+}
@@ -0,0 +1,203 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+)
+
+// smtpTimeout bounds a whole SMTP conversation
+const smtpTimeout = 30 * time.Second
+
+// SMTPOptions are the server, envelope and security settings of an SMTP notifier
+type SMTPOptions struct {
+	// Addr is the server as host:port
+	Addr string
+	From string
+	To   []string
+
+	// TLS is how the connection is secured, STARTTLS when the server offers it if empty
+	TLS config.TLSMode
+
+	// Username and Password enable PLAIN authentication when set
+	Username string
+	Password string
+}
+
+// SMTP sends notifications as email through an SMTP server, such as a local relay
+type SMTP struct {
+	name    string
+	options SMTPOptions
+
+	// rootCAs verifies the server certificate, the system roots when nil
+	rootCAs *x509.CertPool
+}
+
+// NewSMTP returns a notifier sending email with the options
+func NewSMTP(name string, options SMTPOptions) *SMTP {
+	return &SMTP{name: name, options: options}
+}
+
+func (n *SMTP) Name() string {
+	return n.name
+}
+
+// Notify emails the report to the message's recipients, falling back to the notifier's
+func (n *SMTP) Notify(ctx context.Context, message Message) error {
+	to := message.To
+	if len(to) == 0 {
+		to = n.options.To
+	}
+	return n.send(ctx, to, message)
+}
+
+// NotifyOwner emails the report to the owner, who must be named by their email address
+func (n *SMTP) NotifyOwner(ctx context.Context, owner string, message Message) error {
+	if !strings.Contains(owner, "@") {
+		return fmt.Errorf("owner %q is not an email address", owner)
+	}
+	return n.send(ctx, []string{owner}, message)
+}
+
+// send delivers the message to the recipients
+func (n *SMTP) send(ctx context.Context, to []string, message Message) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	client, err := n.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(n.options.From); err != nil {
+		return fmt.Errorf("sender rejected: %w", err)
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", recipient, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.compose(to, message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+	return client.Quit()
+}
+
+// connect opens an SMTP session, secured and authenticated as configured
+func (n *SMTP) connect(ctx context.Context) (*smtp.Client, error) {
+	host, _, _ := net.SplitHostPort(n.options.Addr)
+	tlsConfig := &tls.Config{ServerName: host, RootCAs: n.rootCAs}
+
+	var conn net.Conn
+	var err error
+	if n.options.TLS == config.TLSImplicit {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", n.options.Addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", n.options.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+
+	if n.options.TLS == "" || n.options.TLS == config.TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		} else if n.options.TLS == config.TLSStartTLS {
+			client.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+	}
+
+	if n.options.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, errors.New("server does not support authentication")
+		}
+		// PlainAuth refuses to send the password unencrypted, unless to a local host
+		if err := client.Auth(smtp.PlainAuth("", n.options.Username, n.options.Password, host)); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// compose returns the email with its headers. With an HTML rendering, the body is sent as
+// multipart/alternative with both; otherwise as a single part in the message format.
+func (n *SMTP) compose(to []string, message Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.options.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		contentType := "text/plain"
+		if message.Format == config.FormatHTML {
+			contentType = "text/html"
+		}
+		header := textHeader(contentType)
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n", header.Get("Content-Type"))
+		fmt.Fprintf(&buf, "Content-Transfer-Encoding: %s\r\n\r\n", header.Get("Content-Transfer-Encoding"))
+		writeQuotedPrintable(&buf, message.Body)
+		return buf.Bytes()
+	}
+
+	parts := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{{"text/plain", message.Body}, {"text/html", message.HTML}} {
+		w, _ := parts.CreatePart(textHeader(part.contentType))
+		writeQuotedPrintable(w, part.body)
+	}
+	parts.Close()
+	return buf.Bytes()
+}
+
+// textHeader returns the headers of a quoted-printable UTF-8 text part
+func textHeader(contentType string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	}
+}
+
+// writeQuotedPrintable writes the text quoted-printable encoded, with CRLF line breaks
+func writeQuotedPrintable(w io.Writer, text string) {
+	encoder := quotedprintable.NewWriter(w)
+	encoder.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	encoder.Close()
+}
@@ -0,0 +1,357 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rayselfs/aws-ec2-runtime-checker/internal/config"
+)
+
+// email is a message received by the fake SMTP server
+type email struct {
+	from string
+	to   []string
+	data string
+	// secure reports whether the message was received over TLS
+	secure bool
+	// user is who authenticated, if anyone
+	user string
+}
+
+// smtpServer is an in-process SMTP server accepting every message, except for rejected recipients
+type smtpServer struct {
+	addr string
+
+	// rejected is a recipient the server refuses
+	rejected string
+	// tls, if set, is offered with STARTTLS or, when implicit, used from the start
+	tls      *tls.Config
+	implicit bool
+	// username and password, if set, are required before sending
+	username, password string
+
+	mu     sync.Mutex
+	emails []email
+}
+
+// start serves SMTP on a local port until the test ends
+func (s *smtpServer) start(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	if s.implicit {
+		listener = tls.NewListener(listener, s.tls)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s.addr = listener.Addr().String()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// serve speaks just enough SMTP for net/smtp to deliver one message per transaction
+func (s *smtpServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	r := bufio.NewReader(conn)
+	// reply writes a reply of one or more lines, such as the EHLO extensions
+	reply := func(lines ...string) {
+		for i, line := range lines {
+			separator := "-"
+			if i == len(lines)-1 {
+				separator = " "
+			}
+			io.WriteString(conn, line[:3]+separator+line[4:]+"\r\n")
+		}
+	}
+
+	secure := s.implicit
+	var user string
+	var current email
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case command == "EHLO" || command == "HELO":
+			lines := []string{"250 localhost"}
+			if s.tls != nil && !secure {
+				lines = append(lines, "250 STARTTLS")
+			}
+			if s.username != "" {
+				lines = append(lines, "250 AUTH PLAIN")
+			}
+			reply(lines...)
+		case command == "STARTTLS" && s.tls != nil && !secure:
+			reply("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case command == "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			if s.username == "" || string(credentials) != "\x00"+s.username+"\x00"+s.password {
+				reply("535 Authentication credentials invalid")
+				continue
+			}
+			user = s.username
+			reply("235 Authentication successful")
+		case command == "MAIL":
+			if s.username != "" && user == "" {
+				reply("530 Authentication required")
+				continue
+			}
+			current = email{from: strings.Trim(strings.TrimPrefix(line[5:], "FROM:"), "<>"), secure: secure, user: user}
+			reply("250 OK")
+		case command == "RCPT":
+			to := strings.Trim(strings.TrimPrefix(line[5:], "TO:"), "<>")
+			if to == s.rejected {
+				reply("550 No such user")
+				continue
+			}
+			current.to = append(current.to, to)
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			current.data = data.String()
+			s.mu.Lock()
+			s.emails = append(s.emails, current)
+			s.mu.Unlock()
+			reply("250 Queued")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// received returns the messages received so far
+func (s *smtpServer) received() []email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]email(nil), s.emails...)
+}
+
+// testCertificate returns a server TLS config with a certificate for 127.0.0.1, and a pool trusting it
+func testCertificate(t *testing.T) (*tls.Config, *x509.CertPool) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return &tls.Config{Certificates: server.TLS.Certificates}, roots
+}
+
+func TestSMTP_Security(t *testing.T) {
+	serverTLS, roots := testCertificate(t)
+
+	tests := []struct {
+		name       string
+		server     *smtpServer
+		options    SMTPOptions
+		wantSecure bool
+		wantUser   string
+		wantErr    string
+	}{
+		{
+			name:   "plain relay",
+			server: &smtpServer{},
+		},
+		{
+			name:       "opportunistic STARTTLS",
+			server:     &smtpServer{tls: serverTLS},
+			wantSecure: true,
+		},
+		{
+			name:    "STARTTLS disabled",
+			server:  &smtpServer{tls: serverTLS},
+			options: SMTPOptions{TLS: config.TLSNone},
+		},
+		{
+			name:       "required STARTTLS with authentication",
+			server:     &smtpServer{tls: serverTLS, username: "checker", password: "s3cret"},
+			options:    SMTPOptions{TLS: config.TLSStartTLS, Username: "checker", Password: "s3cret"},
+			wantSecure: true,
+			wantUser:   "checker",
+		},
+		{
+			name:       "implicit TLS with authentication",
+			server:     &smtpServer{tls: serverTLS, implicit: true, username: "checker", password: "s3cret"},
+			options:    SMTPOptions{TLS: config.TLSImplicit, Username: "checker", Password: "s3cret"},
+			wantSecure: true,
+			wantUser:   "checker",
+		},
+		{
+			name:    "required STARTTLS not offered",
+			server:  &smtpServer{},
+			options: SMTPOptions{TLS: config.TLSStartTLS},
+			wantErr: "does not support STARTTLS",
+		},
+		{
+			name:    "wrong password",
+			server:  &smtpServer{tls: serverTLS, username: "checker", password: "s3cret"},
+			options: SMTPOptions{Username: "checker", Password: "wrong"},
+			wantErr: "535",
+		},
+		{
+			name:    "authentication not offered",
+			server:  &smtpServer{},
+			options: SMTPOptions{Username: "checker", Password: "s3cret"},
+			wantErr: "does not support authentication",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.server.start(t)
+			options := tt.options
+			options.Addr, options.From, options.To = server.addr, "checker@example.com", []string{"ops@example.com"}
+			n := NewSMTP("mail", options)
+			n.rootCAs = roots
+
+			err := n.Notify(context.Background(), Message{Subject: "Alert", Body: "i-1 stopped\n"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				if emails := server.received(); len(emails) != 0 {
+					t.Errorf("Expected no email, got %d", len(emails))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Notify() failed: %v", err)
+			}
+
+			emails := server.received()
+			if len(emails) != 1 {
+				t.Fatalf("Expected one email, got %d", len(emails))
+			}
+			if emails[0].secure != tt.wantSecure || emails[0].user != tt.wantUser {
+				t.Errorf("Expected secure=%v and user %q, got secure=%v and user %q", tt.wantSecure, tt.wantUser, emails[0].secure, emails[0].user)
+			}
+		})
+	}
+}
+
+func TestSMTP_Notify(t *testing.T) {
+	server := (&smtpServer{}).start(t)
+	n := NewSMTP("mail", SMTPOptions{Addr: server.addr, From: "checker@example.com", To: []string{"ops@example.com", "dev@example.com"}})
+
+	message := Message{Subject: "Alert: 2 instances", Body: "i-1 stopped\n", HTML: "<p>i-1 stopped</p>\n"}
+	if err := n.Notify(context.Background(), message); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+	message.To = []string{"ml-team@example.com"}
+	if err := n.Notify(context.Background(), message); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+
+	emails := server.received()
+	if len(emails) != 2 {
+		t.Fatalf("Expected two emails, got %d", len(emails))
+	}
+	if emails[0].from != "checker@example.com" || strings.Join(emails[0].to, ",") != "ops@example.com,dev@example.com" {
+		t.Errorf("Unexpected envelope from %s to %v", emails[0].from, emails[0].to)
+	}
+	if strings.Join(emails[1].to, ",") != "ml-team@example.com" {
+		t.Errorf("Expected the message's recipients instead of the notifier's, got %v", emails[1].to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); subject != message.Subject {
+		t.Errorf("Expected subject %q, got %q", message.Subject, subject)
+	}
+	if to := parsed.Header.Get("To"); to != "ops@example.com, dev@example.com" {
+		t.Errorf("Expected the recipients in the To header, got %q", to)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Expected a multipart/alternative email, got %s", mediaType)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "i-1 stopped\r\n"},
+		{"text/html; charset=utf-8", "<p>i-1 stopped</p>\r\n"},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("Expected a %s part, got %v", want.contentType, err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		if part.Header.Get("Content-Type") != want.contentType || string(body) != want.body {
+			t.Errorf("Expected %s part %q, got %s part %q", want.contentType, want.body, part.Header.Get("Content-Type"), body)
+		}
+	}
+}
+
+func TestSMTP_NotifyOwner(t *testing.T) {
+	server := (&smtpServer{rejected: "gone@example.com"}).start(t)
+	n := NewSMTP("mail", SMTPOptions{Addr: server.addr, From: "checker@example.com", To: []string{"ops@example.com"}})
+	message := Message{Subject: "Your instances", Body: "<p>i-1 stopped</p>\n", Format: config.FormatHTML, To: []string{"ml-team@example.com"}}
+
+	if err := n.NotifyOwner(context.Background(), "alice@example.com", message); err != nil {
+		t.Fatalf("NotifyOwner() failed: %v", err)
+	}
+	emails := server.received()
+	if len(emails) != 1 || strings.Join(emails[0].to, ",") != "alice@example.com" {
+		t.Fatalf("Expected one email to the owner only, got %v", emails)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+	if got := parsed.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Expected a single HTML part, got %s", got)
+	}
+
+	if err := n.NotifyOwner(context.Background(), "alice", message); err == nil {
+		t.Error("Expected an error for an owner without an email address")
+	}
+	if err := n.NotifyOwner(context.Background(), "gone@example.com", message); err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("Expected the rejection of the recipient, got %v", err)
+	}
+}
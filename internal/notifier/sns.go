@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// OwnerAttribute is the message attribute holding the owner of a personal message, for
+// subscription filter policies
+const OwnerAttribute = "owner"
+
+// maxSNSSubjectLength is the longest subject SNS accepts, in characters
+const maxSNSSubjectLength = 100
+
+// SNS publishes notifications to an SNS topic
+type SNS struct {
+	name     string
+	client   SNSAPI
+	topicArn string
+}
+
+// NewSNS returns a notifier publishing to the topic
+func NewSNS(name string, client SNSAPI, topicArn string) *SNS {
+	return &SNS{name: name, client: client, topicArn: topicArn}
+}
+
+func (n *SNS) Name() string {
+	return n.name
+}
+
+// Notify publishes the report to the topic
+func (n *SNS) Notify(ctx context.Context, message Message) error {
+	return n.publish(ctx, message, nil)
+}
+
+// NotifyOwner publishes the report to the topic with the owner as message attribute, so
+// subscriptions with a filter policy on it, such as the owner's email subscription, receive it
+func (n *SNS) NotifyOwner(ctx context.Context, owner string, message Message) error {
+	return n.publish(ctx, message, map[string]types.MessageAttributeValue{
+		OwnerAttribute: {DataType: aws.String("String"), StringValue: aws.String(owner)},
+	})
+}
+
+// publish publishes the report to the topic with the message attributes
+func (n *SNS) publish(ctx context.Context, message Message, attributes map[string]types.MessageAttributeValue) error {
+	_, err := n.client.Publish(ctx, &sns.PublishInput{
+		Message:           aws.String(message.Body),
+		TopicArn:          aws.String(n.topicArn),
+		Subject:           aws.String(snsSubject(message.Subject)),
+		MessageAttributes: attributes,
+	})
+	return err
+}
+
+// snsSubject makes the subject acceptable to SNS, which rejects subjects with line breaks or
+// control characters and subjects longer than maxSNSSubjectLength: control characters become
+// spaces and a longer subject is cut short with an ellipsis
+func snsSubject(subject string) string {
+	subject = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, subject))
+	if runes := []rune(subject); len(runes) > maxSNSSubjectLength {
+		subject = string(runes[:maxSNSSubjectLength-3]) + "..."
+	}
+	return subject
+}
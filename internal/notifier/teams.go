@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+)
+
+// Teams posts notifications to a Microsoft Teams incoming webhook or workflow as an Adaptive Card
+type Teams struct {
+	name string
+	url  string
+}
+
+// NewTeams returns a notifier posting to the webhook URL
+func NewTeams(name, url string) *Teams {
+	return &Teams{name: name, url: url}
+}
+
+func (n *Teams) Name() string {
+	return n.name
+}
+
+// teamsPayload is a message holding a single Adaptive Card
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	Body    []teamsTextBlock `json:"body"`
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Wrap   bool   `json:"wrap"`
+}
+
+// Notify posts the report as a card titled with the subject
+func (n *Teams) Notify(ctx context.Context, message Message) error {
+	payload := teamsPayload{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body: []teamsTextBlock{
+					{Type: "TextBlock", Text: message.Subject, Weight: "Bolder", Size: "Medium", Wrap: true},
+					// Text blocks join single line breaks, so every line becomes a paragraph
+					{Type: "TextBlock", Text: strings.ReplaceAll(strings.TrimSpace(message.Body), "\n", "\n\n"), Wrap: true},
+				},
+			},
+		}},
+	}
+	return postJSON(ctx, n.url, nil, payload)
+}
@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+)
+
+// Webhook posts notifications as JSON to any HTTP endpoint
+type Webhook struct {
+	name    string
+	url     string
+	headers map[string]string
+}
+
+// NewWebhook returns a notifier posting to the URL with the extra headers
+func NewWebhook(name, url string, headers map[string]string) *Webhook {
+	return &Webhook{name: name, url: url, headers: headers}
+}
+
+func (n *Webhook) Name() string {
+	return n.name
+}
+
+// webhookPayload is the body of a webhook request
+type webhookPayload struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	Format  string `json:"format,omitempty"`
+	Report  any    `json:"report,omitempty"`
+}
+
+// Notify posts the subject, the rendered report with its format and the structured report
+func (n *Webhook) Notify(ctx context.Context, message Message) error {
+	payload := webhookPayload{Subject: message.Subject, Text: message.Body, Format: string(message.Format), Report: message.Data}
+	return postJSON(ctx, n.url, n.headers, payload)
+}